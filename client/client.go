@@ -0,0 +1,159 @@
+/*
+Package client is a Go client of the JSON bot API of GoLab, so bots playing the game can be developed
+without scraping the view images.
+
+A bot has its own browser session (identified by a cookie) just like a browser, so it plays its own game
+(or it can join the game of others with Join()). The session is started by NewGame() or Join(), the other
+requests fail without a session. A simple bot which walks to the exit:
+
+	c, err := client.New("http://localhost:1234/")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := c.NewGame(model.NewGame{}); err != nil {
+		log.Fatal(err)
+	}
+	st, err := c.State()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Waypoint(st.Exit); err != nil {
+		log.Fatal(err)
+	}
+	for !st.Won && !st.Dead {
+		time.Sleep(100 * time.Millisecond)
+		if st, err = c.State(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+Instead of polling the state, a bot can open a WebSocket with Client.Socket(): the server pushes the game events
+(and the states and the view images if subscribed) on it, and the commands can also be sent on it.
+*/
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gophergala/golab/model"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// Client is a client of the JSON bot API of a GoLab server.
+type Client struct {
+	// base is the base URL of the GoLab server
+	base *url.URL
+
+	// hc is the HTTP client holding the session cookie
+	hc *http.Client
+}
+
+// New returns a new Client connecting to the GoLab server at the specified URL (e.g. "http://localhost:1234/").
+// The Client has no session until NewGame() or Join() is called.
+func New(serverURL string) (*Client, error) {
+	base, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{base: base, hc: &http.Client{Jar: jar}}, nil
+}
+
+// State returns the state of the game of the client.
+func (c *Client) State() (*model.State, error) {
+	st := &model.State{}
+	if err := c.do("GET", "api/v1/state", nil, st); err != nil {
+		return nil, err
+	}
+	if st.Version != model.APIVersion {
+		return nil, fmt.Errorf("unsupported API version: %d", st.Version)
+	}
+	return st, nil
+}
+
+// Waypoint queues the specified block (in block coordinates) as a target:
+// the Gopher of the client (or the Bulldog if the client is the hunter) moves there along the shortest path
+// after reaching the already queued targets.
+func (c *Client) Waypoint(block image.Point) error {
+	return c.Command(model.Command{Waypoint: &block})
+}
+
+// Clear clears the queued targets.
+func (c *Client) Clear() error {
+	return c.Command(model.Command{Clear: true})
+}
+
+// Key sends a direction key event: the Gopher of the client moves in the direction as long as the key is pressed.
+func (c *Client) Key(dir model.Dir, pressed bool) error {
+	return c.Command(model.Command{Dir: dir.String(), Pressed: pressed})
+}
+
+// Command sends the specified command.
+func (c *Client) Command(cmd model.Command) error {
+	return c.do("POST", "api/v1/command", cmd, nil)
+}
+
+// NewGame starts a new game with the specified parameters (zero values mean defaults),
+// starting the session of the client if it has none.
+// Returns the normalized parameters of the new game.
+func (c *Client) NewGame(ng model.NewGame) (model.NewGame, error) {
+	err := c.do("POST", "api/v1/new", ng, &ng)
+	return ng, err
+}
+
+// Join joins the game having the specified id as a new player (or as the hunter if hunter is true).
+// The id of a game is the "g" parameter of its invite link. The session of the client is started if it has none.
+func (c *Client) Join(gameId string, hunter bool) error {
+	q := url.Values{"g": {gameId}}
+	if hunter {
+		q.Set("as", "hunter")
+	}
+	return c.do("GET", "join?"+q.Encode(), nil, nil)
+}
+
+// do performs an HTTP request to the specified path (relative to the base URL).
+// If in is not nil, it is sent as JSON in the request body. If out is not nil, the JSON response is decoded into it.
+func (c *Client) do(method, path string, in, out interface{}) error {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.base.ResolveReference(ref).String(), &body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gophergala/golab/model"
+	"github.com/gophergala/golab/websocket"
+	"image"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Number of attempts to reconnect a broken WebSocket, and the delay between them
+const (
+	reconnectAttempts = 5
+	reconnectDelay    = time.Second
+)
+
+// maxMessageSize is the maximum size of the messages received from the server: frames (JPEG images) and scenes
+// are much larger than the requests the server receives (see websocket.MaxMessageSize).
+const maxMessageSize = 8 << 20
+
+// Socket is a WebSocket connection of a Client: requests are sent on it, and the server pushes game events,
+// states and frames (if subscribed) on it, so bots don't have to poll the state.
+//
+// A broken connection is reconnected automatically with the session of the Client, resuming its game;
+// the subscription is sent again after reconnecting. Receive is to be called by one goroutine at a time,
+// the other methods are safe for concurrent use.
+type Socket struct {
+	// c is the Client of the socket
+	c *Client
+
+	// mutex protects conn and sub
+	mutex sync.Mutex
+
+	// conn is the current connection
+	conn *websocket.Conn
+
+	// sub is the last subscribe request (nil if none), sent again after reconnecting
+	sub *model.SocketRequest
+
+	// closed tells if the socket was closed by Close
+	closed bool
+}
+
+// Socket opens a WebSocket connection to the server with the session of the client.
+// The first message received on it is a model.SocketHello.
+func (c *Client) Socket() (*Socket, error) {
+	s := &Socket{c: c}
+	conn, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+// dial opens a new connection with the session cookie of the client.
+func (s *Socket) dial() (*websocket.Conn, error) {
+	u := s.c.base.ResolveReference(&url.URL{Path: "api/v1/socket"})
+	header := http.Header{}
+	for _, ck := range s.c.hc.Jar.Cookies(u) {
+		header.Add("Cookie", ck.String())
+	}
+	conn, _, err := websocket.Dial(u.String(), header)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadLimit(maxMessageSize)
+	return conn, nil
+}
+
+// reconnect replaces the specified broken connection with a new one (unless it was already replaced),
+// and sends the subscription again.
+func (s *Socket) reconnect(broken *websocket.Conn) (*websocket.Conn, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil, errors.New("socket is closed")
+	}
+	if s.conn != broken {
+		return s.conn, nil // Already reconnected
+	}
+	broken.Close()
+
+	var err error
+	for i := 0; i < reconnectAttempts; i++ {
+		if i > 0 {
+			time.Sleep(reconnectDelay)
+		}
+		var conn *websocket.Conn
+		if conn, err = s.dial(); err != nil {
+			continue
+		}
+		if s.sub != nil {
+			if err = writeJSON(conn, s.sub); err != nil {
+				conn.Close()
+				continue
+			}
+		}
+		s.conn = conn
+		return conn, nil
+	}
+	return nil, fmt.Errorf("failed to reconnect: %v", err)
+}
+
+// Receive returns the next message pushed by the server. Frames are returned as model.SocketFrame messages.
+// If the connection is broken, it is reconnected, resuming the session (a model.SocketHello message is received
+// again on the new connection).
+func (s *Socket) Receive() (*model.SocketMessage, error) {
+	s.mutex.Lock()
+	conn := s.conn
+	s.mutex.Unlock()
+
+	for {
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			if conn, err = s.reconnect(conn); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if typ == websocket.BinaryMessage {
+			return &model.SocketMessage{Type: model.SocketFrame, Frame: data}, nil
+		}
+		msg := &model.SocketMessage{}
+		if err := json.Unmarshal(data, msg); err != nil {
+			return nil, err
+		}
+		if msg.State != nil && msg.State.Version != model.APIVersion {
+			return nil, fmt.Errorf("unsupported API version: %d", msg.State.Version)
+		}
+		return msg, nil
+	}
+}
+
+// Send sends the specified request. If the connection is broken, it is reconnected and the request is sent again.
+func (s *Socket) Send(req model.SocketRequest) error {
+	s.mutex.Lock()
+	conn := s.conn
+	if req.Type == model.SocketSubscribe {
+		s.sub = &req
+	}
+	s.mutex.Unlock()
+
+	if err := writeJSON(conn, &req); err != nil {
+		if conn, err = s.reconnect(conn); err != nil {
+			return err
+		}
+		if req.Type == model.SocketSubscribe {
+			return nil // Sent by reconnect
+		}
+		return writeJSON(conn, &req)
+	}
+	return nil
+}
+
+// Subscribe subscribes to the states of the game (if states is true) and to the frames of the view
+// (if frames is true, in JPEG format with the specified quality), sent at most fps times per second.
+func (s *Socket) Subscribe(states, frames bool, fps, quality int) error {
+	return s.Send(model.SocketRequest{Type: model.SocketSubscribe, States: states, Frames: frames, FPS: fps, Quality: &quality})
+}
+
+// Waypoint queues the specified block (in block coordinates) as a target, see Client.Waypoint.
+func (s *Socket) Waypoint(block image.Point) error {
+	return s.Command(model.Command{Waypoint: &block})
+}
+
+// Clear clears the queued targets.
+func (s *Socket) Clear() error {
+	return s.Command(model.Command{Clear: true})
+}
+
+// Key sends a direction key event, see Client.Key.
+func (s *Socket) Key(dir model.Dir, pressed bool) error {
+	return s.Command(model.Command{Dir: dir.String(), Pressed: pressed})
+}
+
+// Command sends the specified command. Errors of the command are received as model.SocketError messages.
+func (s *Socket) Command(cmd model.Command) error {
+	return s.Send(model.SocketRequest{Type: model.SocketCommand, Command: &cmd})
+}
+
+// NewGame starts a new game with the specified parameters (zero values mean defaults).
+// The normalized parameters are received in a model.SocketNewGame message.
+func (s *Socket) NewGame(ng model.NewGame) error {
+	return s.Send(model.SocketRequest{Type: model.SocketNew, Game: &ng})
+}
+
+// Close closes the socket.
+func (s *Socket) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return s.conn.Close()
+}
+
+// writeJSON writes the specified value as JSON in a text message.
+func writeJSON(conn *websocket.Conn, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
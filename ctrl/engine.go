@@ -2,139 +2,459 @@ package ctrl
 
 import (
 	"github.com/gophergala/golab/model"
-	"github.com/gophergala/golab/view"
 	"image"
-	"image/draw"
 	"math"
-	"math/rand"
 	"time"
 )
 
-// LoopDelay is the delay between the iterations of the main loop of the game engine, in milliseconds.
+// LoopDelay is the default delay between the iterations of the main loop of the game engines, in milliseconds.
+// Each iteration renders a new frame, so this determines the frame rate of the views.
 var LoopDelay = 50 // ~20 FPS
 
-// InitNew initializes a new game.
-func initNew() {
-	// Initialize random number generator
-	rand.Seed(time.Now().Unix())
+// TickRate is the default number of simulation ticks per second.
+// The game logic is stepped in fixed ticks (independent from the loop delay and the scheduling of the engine),
+// so identical inputs at identical ticks always produce identical outcomes.
+var TickRate = 60
 
-	model.InitNew()
-	view.InitNew()
+// maxFrameTime is the maximum time to be simulated in one iteration of the main loop.
+// If the engine falls behind more than this (e.g. the computer was suspended),
+// the game slows down instead of simulating a huge number of ticks at once.
+const maxFrameTime = 250 * time.Millisecond
+
+// engine is the game engine of a Game: it simulates the game in its own goroutine.
+// Fields must only be accessed while holding the Mutex of the game (or from the goroutine of the engine).
+type engine struct {
+	// g is the game simulated by the engine
+	g *model.Game
+
+	// loopDelay is the delay between the iterations of the main loop, in milliseconds
+	loopDelay int
+
+	// tickRate is the number of simulation ticks per second
+	tickRate int
+
+	// Duration of a simulation tick
+	tickDuration time.Duration
+
+	// Delta time of a simulation tick in seconds
+	dt float64
+
+	// Keyboard control state of the players
+	keys []keys
+
+	// spotted tells which Gophers the Bulldogs see: bit i of spotted[j] is set if Bulldog j sees the Gopher of player i
+	spotted []int
+
+	// Replay state
+	replayState
+}
+
+// StartEngine creates a new game and starts its engine in a new goroutine, and returns as soon as possible.
+// The first game is started when a NewGame is sent on the NewGameCh of the game,
+// so the caller can subscribe to the events of the game before.
+// The engine stops when the Quit channel of the game is closed.
+func StartEngine() *model.Game {
+	g := model.CreateGame()
+
+	e := &engine{g: g, loopDelay: LoopDelay}
+	e.setTickRate(TickRate)
+
+	g.Mutex.Lock()
+
+	go e.simulate()
+
+	return g
 }
 
-// StartEngine starts the game engine in a new goroutine and returns as soon as possible.
-func StartEngine() {
-	model.NewGameCh <- 1 // Cannot block as application was just started, no incoming requests processed yet
+// initNew initializes a new game.
+func (e *engine) initNew(ng model.NewGame) {
+	e.saveRecording()
+	e.g.InitNew(ng)
+	e.startRecording()
+	e.resetKeys()
+	e.spotted = make([]int, len(e.g.Bulldogs))
 
-	model.Mutex.Lock()
+	curGame := e.g.CurGame
+	e.publish(model.Event{Type: model.EventGameStarted, Game: &curGame})
+}
 
-	go simulate()
+// publish publishes the specified event of the current tick on the event bus of the game.
+// Events of ticks re-simulated while seeking in a replay are not published.
+func (e *engine) publish(ev model.Event) {
+	g := e.g
+	if e.seeking {
+		return
+	}
+	ev.Tick = g.Tick
+	ev.Replay = g.Playback != nil
+	g.Events.Publish(ev)
 }
 
-// Delta time since our last iteration
-var dt float64
+// setTickRate sets the tick rate of the simulation.
+func (e *engine) setTickRate(rate int) {
+	e.tickRate = rate
+	e.tickDuration = time.Second / time.Duration(rate)
+	e.dt = e.tickDuration.Seconds()
+}
 
-// simulate implements the game cycle
-func simulate() {
-	t := time.Now().UnixNano()
+// simulate implements the game cycle.
+//
+// Wall-clock time elapsed since the previous iteration is accumulated, and the game logic is stepped
+// in as many fixed ticks as fit into the accumulated time. The remainder is carried over to the next iteration.
+// A new frame is rendered once per iteration, decoupled from the ticks.
+//
+// The Mutex of the game must be locked when called.
+func (e *engine) simulate() {
+	g := e.g
+
+	// Wait for the first game
+	select {
+	case <-g.Quit:
+		g.Events.Close()
+		g.Mutex.Unlock()
+		return
+	case ng := <-g.NewGameCh:
+		e.initNew(ng)
+	}
+
+	last := time.Now()
+	// acc is the accumulated time not yet simulated
+	var acc time.Duration
 
 	for {
-		// Check if we have to start a new game
+		// Check if we have to stop or start a new game
 		select {
-		case <-model.NewGameCh:
-			initNew()
+		case <-g.Quit:
+			e.saveRecording()
+			g.Events.Close()
+			g.Mutex.Unlock()
+			return
+		case ng := <-g.NewGameCh:
+			// A new game ends the replay mode
+			e.stopPlayback()
+			e.initNew(ng)
+			acc = 0
 		default:
 		}
 
-		// First erase target images. We have to do this before handling mouse clicks
-		// as they may change the target positions
-		eraseDrawTargetPoss(true)
-
-		// Process mouse clicks
-	clickLoop:
+		// Process pause commands
+	pauseLoop:
 		for {
 			select {
-			case click := <-model.ClickCh:
-				handleClick(click)
+			case paused := <-g.PauseCh:
+				e.handlePause(paused)
 			default:
-				break clickLoop
+				break pauseLoop
 			}
 		}
 
-		// Next clear moving objects from the lab image:
-		model.Gopher.EraseImg()
-		for _, bd := range model.Bulldogs {
-			bd.EraseImg()
+		// Check replay commands
+		select {
+		case cmd := <-g.ReplayCh:
+			e.handleReplayCmd(cmd)
+			acc = 0
+		default:
+		}
+
+		now := time.Now()
+		if e.seeking {
+			// Seeking time is not simulated
+			e.discardInputs()
+			e.seekStep()
+			acc = 0
+		} else if g.Paused {
+			// Paused time is not simulated (and inputs are discarded)
+			e.discardInputs()
+			acc = 0
+		} else {
+			acc += now.Sub(last)
+			if acc > maxFrameTime {
+				acc = maxFrameTime
+			}
+			for ; acc >= e.tickDuration && !g.Won && !e.playbackEnded(); acc -= e.tickDuration {
+				e.tick()
+			}
+			if e.playbackEnded() {
+				// Reached the end of the replay, pause so it can be resumed after seeking back
+				g.Paused = true
+			}
 		}
+		last = now
 
-		model.DrawImgAt(model.ExitImg, model.ExitPos.X, model.ExitPos.X)
+		e.render()
 
-		if !model.Dead {
-			// Draw target positions
-			eraseDrawTargetPoss(false)
+		// Sleep some time.
+		// Iterations might not be exact, but we don't rely on it:
+		// the time not simulated in this iteration is carried over to the next one.
+
+		g.Mutex.Unlock() // While sleeping, other goroutines can modify the game (e.g. players can join)
+		if g.Won {
+			// If won, nothing has to be done, just wait for a new game signal
+			e.waitNewGame()
+			// Waiting time must not be simulated
+			last = time.Now()
 		}
+		time.Sleep(time.Millisecond * time.Duration(e.loopDelay))
+		g.Mutex.Lock() // We will modify model now, so lock.
+	}
+}
+
+// tick performs a simulation tick: processes inputs and steps the game logic with a fixed delta time.
+func (e *engine) tick() {
+	g := e.g
 
-		now := time.Now().UnixNano()
-		dt = float64(now-t) / 1e9
+	e.processInputs()
 
-		// Now step moving objects
+	if g.Racing() {
+		g.LevelTime += e.tickDuration
+	}
 
-		stepGopher()
-		stepBulldogs()
+	// Now step moving objects
 
-		// Check if Gopher reached the exit point
-		if int(model.Gopher.Pos.X) == model.ExitPos.X && int(model.Gopher.Pos.Y) == model.ExitPos.Y {
-			handleWinning()
+	for i := range g.Players {
+		e.stepGopher(i)
+	}
+	e.stepBulldogs()
+
+	g.Tick++
+
+	// Check if Gophers reached the exit point
+	for i, p := range g.Players {
+		if p.Racing() && int(p.Pos.X) == g.ExitPos.X && int(p.Pos.Y) == g.ExitPos.Y {
+			p.Finished, p.FinishTime = true, g.LevelTime
+			e.publish(model.Event{Type: model.EventGopherWon, Player: i, Block: p.Block()})
 		}
+	}
+	if g.Hunting {
+		if g.HuntOver() {
+			e.handleWinning()
+		}
+	} else if !g.Racing() && g.Finished() {
+		// The race is over when a Gopher reached the exit and no one else is racing
+		e.handleWinning()
+	}
+}
 
-		t = now
+// processInputs processes the players who left the game, the queued mouse clicks and key events.
+// If a replay is being played back, the queued inputs are discarded, and the inputs of the replay are applied instead.
+func (e *engine) processInputs() {
+	g := e.g
 
-		// Sleep some time.
-		// Iterations might not be exact, but we don't rely on it:
-		// We calculate delta time and calculate moving and next positions
-		// based on the delta time.
+	if g.Playback != nil {
+		e.discardInputs()
+		e.applyReplayInputs()
+		return
+	}
 
-		model.Mutex.Unlock() // While sleeping, clients can request view images
-		if model.Won {
-			// If won, nothing has to be done, just wait for a new game signal
-			<-model.NewGameCh // Blocking receive
-			// Send back value to detect it at the proper place
-			model.NewGameCh <- 1
+	// Players who left the game forfeit the race
+	for i, p := range g.Players {
+		if g.Left[i] && p.Racing() {
+			e.handleInput(model.Input{Leave: &model.Leave{Player: i}})
+		}
+	}
+
+	// Process mouse clicks
+clickLoop:
+	for {
+		select {
+		case click := <-g.ClickCh:
+			e.handleInput(model.Input{Click: &click})
+		default:
+			break clickLoop
+		}
+	}
+
+	// Process key events
+keyLoop:
+	for {
+		select {
+		case key := <-g.KeyCh:
+			e.handleInput(model.Input{Key: &key})
+		default:
+			break keyLoop
+		}
+	}
+}
+
+// discardInputs discards the queued mouse clicks and key events.
+func (e *engine) discardInputs() {
+	for {
+		select {
+		case <-e.g.ClickCh:
+		case <-e.g.KeyCh:
+		default:
+			return
+		}
+	}
+}
+
+// render produces a new frame of the game: publishes a new snapshot of the state of the game.
+// Nothing is drawn by the engine: the views are composed from the latest snapshot (see model.Snapshot.Compose)
+// when they are requested.
+func (e *engine) render() {
+	e.g.Frame++
+	e.g.PublishSnapshot()
+}
+
+// waitNewGame waits for a new game signal, and sends it back to detect it at the proper place.
+// In campaign mode if there is a next level, a mouse click also starts the next level.
+// In replay mode replay commands are also waited for (e.g. to seek back).
+// Quitting is also detected.
+func (e *engine) waitNewGame() {
+	g := e.g
+
+	var clickCh chan model.Click
+	if len(g.Campaign) > 0 && g.HasNextLevel() {
+		clickCh = g.ClickCh
+	}
+	var replayCh chan model.ReplayCmd
+	if g.Playback != nil {
+		replayCh = g.ReplayCh
+	}
+
+	select {
+	case <-g.Quit:
+		// Closed channel, will be detected again in the main loop
+	case ng := <-g.NewGameCh: // Blocking receive
+		g.NewGameCh <- ng
+	case cmd := <-replayCh: // Blocks forever if nil
+		// Use non-blocking send: if a newer command arrived meanwhile, that one is kept
+		select {
+		case g.ReplayCh <- cmd:
+		default:
+		}
+	case <-clickCh: // Blocks forever if nil
+		ng := g.CurGame
+		ng.Level++
+		g.NewGameCh <- ng // Cannot block, we are the only receiver and NewGameCh was empty
+	}
+}
+
+// handlePause handles a pause or resume command.
+func (e *engine) handlePause(paused bool) {
+	g := e.g
+
+	if paused && !g.Paused && g.Playback == nil {
+		// Key releases might not arrive while paused (e.g. the browser tab is hidden), so release the held keys.
+		// Do it by handling release inputs so they are recorded too.
+		for i, p := range g.Players {
+			for ks := &e.keys[i]; p.Racing() && len(ks.pressedDirs) > 0; {
+				e.handleInput(model.Input{Key: &model.Key{Dir: ks.pressedDirs[len(ks.pressedDirs)-1], Player: i}})
+			}
 		}
-		time.Sleep(time.Millisecond * time.Duration(LoopDelay))
-		model.Mutex.Lock() // We will modify model now, labyrinth image might change so lock.
+		e.resetKeys()
 	}
+	g.Paused = paused
 }
 
 // handleClick handles a mouse click
-func handleClick(c model.Click) {
-	if model.Dead {
+func (e *engine) handleClick(c model.Click) {
+	g := e.g
+
+	if c.Hunter {
+		e.handleHunterClick(c)
 		return
 	}
 
-	Gopher := model.Gopher
+	if c.Player >= len(g.Players) || !g.Players[c.Player].Racing() || g.Paused {
+		return
+	}
+
+	p := g.Players[c.Player]
 
 	if c.Btn == model.MouseBtnRight {
-		model.TargetPoss = model.TargetPoss[0:0]
+		p.TargetPoss = p.TargetPoss[0:0]
+	}
+
+	// Last target pos:
+	var TargetPos image.Point
+	if len(p.TargetPoss) == 0 {
+		TargetPos = p.TargetPos
+	} else {
+		TargetPos = p.TargetPoss[len(p.TargetPoss)-1]
 	}
+	last := image.Pt(TargetPos.X/model.BlockSize, TargetPos.Y/model.BlockSize)
 
 	// If target buffer is full, do nothing:
-	if len(model.TargetPoss) == cap(model.TargetPoss) {
+	if len(p.TargetPoss) == cap(p.TargetPoss) {
+		e.publishWaypoint(c, last, nil)
 		return
 	}
 
-	// Last target pos:
-	var TargetPos image.Point
-	if len(model.TargetPoss) == 0 {
-		TargetPos = Gopher.TargetPos
-	} else {
-		TargetPos = model.TargetPoss[len(model.TargetPoss)-1]
+	blocks := e.clickedBlocks(last, c)
+	if len(blocks) > cap(p.TargetPoss)-len(p.TargetPoss) {
+		blocks = nil // The path does not fit into the target buffer
+	}
+	for _, block := range blocks {
+		// Use target position rounded to the center of the target block:
+		p.TargetPoss = append(p.TargetPoss, model.BlockCenter(block))
+	}
+	e.publishWaypoint(c, last, blocks)
+}
+
+// handleHunterClick handles a mouse click of the hunter: queues waypoints for the Bulldog of the hunter.
+func (e *engine) handleHunterClick(c model.Click) {
+	g := e.g
+
+	bd := g.HunterBulldog()
+	if bd == nil || g.Paused {
+		return
 	}
+	h := bd.Behavior.(*model.HunterBehavior)
+
+	if c.Btn == model.MouseBtnRight {
+		h.Waypoints = h.Waypoints[0:0]
+	}
+
+	// Last waypoint:
+	last := bd.Block()
+	if len(h.Waypoints) > 0 {
+		last = h.Waypoints[len(h.Waypoints)-1]
+	}
+
+	blocks := e.clickedBlocks(last, c)
+	h.Waypoints = append(h.Waypoints, blocks...)
+	e.publishWaypoint(c, last, blocks)
+}
+
+// publishWaypoint publishes the waypoint event of the specified click:
+// the click is accepted if blocks were queued after the block last (the last target before the click).
+// Clicking the last target itself is neither accepted nor rejected (e.g. clicks only clearing the targets).
+func (e *engine) publishWaypoint(c model.Click, last image.Point, blocks []image.Point) {
+	ev := model.Event{Type: model.EventWaypointAccepted, Player: c.Player, Hunter: c.Hunter,
+		Block: image.Pt(c.X/model.BlockSize, c.Y/model.BlockSize)}
+	if len(blocks) == 0 {
+		if ev.Block == last {
+			return
+		}
+		ev.Type = model.EventWaypointRejected
+	}
+	e.publish(ev)
+}
+
+// clickedBlocks returns the blocks (in block coordinates) to be queued as targets after the specified block
+// in response to a mouse click, each in the same row or column as the previous one with a free passage to it.
+// Returns nil if the clicked block cannot be commanded.
+func (e *engine) clickedBlocks(from image.Point, c model.Click) []image.Point {
+	g := e.g
 
 	// Check if new desired target is in the same row/column as the last target and if there is a free passage to there.
-	pCol, pRow := TargetPos.X/model.BlockSize, TargetPos.Y/model.BlockSize
+	pCol, pRow := from.X, from.Y
 	tCol, tRow := c.X/model.BlockSize, c.Y/model.BlockSize
 
+	if c.PathFinding {
+		// Queue the corners of the shortest path as targets
+		path := model.ShortestPath(g.Lab, image.Pt(pCol, pRow), image.Pt(tCol, tRow))
+		if len(path) < 2 {
+			return nil // Not reachable (or it's the last target itself)
+		}
+		return model.PathCorners(path)
+	}
+
+	if tRow < 0 || tRow >= len(g.Lab) || tCol < 0 || tCol >= len(g.Lab[tRow]) {
+		return nil // Outside of the Labyrinth (inputs of replays and bots are not restricted to the view)
+	}
+
 	// sorted simply returns its parameters in ascendant order:
 	sorted := func(a, b int) (int, int) {
 		if a < b {
@@ -146,149 +466,147 @@ func handleClick(c model.Click) {
 
 	if pCol == tCol { // Same column
 		for row, row2 := sorted(pRow, tRow); row <= row2; row++ {
-			if model.Lab[row][tCol] == model.BlockWall {
-				return // Wall in the route
+			if g.Lab[row][tCol] == model.BlockWall {
+				return nil // Wall in the route
 			}
 		}
 	} else if pRow == tRow { // Same row
 		for col, col2 := sorted(pCol, tCol); col <= col2; col++ {
-			if model.Lab[tRow][col] == model.BlockWall {
-				return // Wall in the route
+			if g.Lab[tRow][col] == model.BlockWall {
+				return nil // Wall in the route
 			}
 		}
 	} else {
-		return // Only the same row or column can be commanded
+		return nil // Only the same row or column can be commanded
 	}
 
 	// Target pos is allowed and reachable.
-	// Use target position rounded to the center of the target block:
-	model.TargetPoss = append(model.TargetPoss, image.Pt(tCol*model.BlockSize+model.BlockSize/2, tRow*model.BlockSize+model.BlockSize/2))
+	return []image.Point{{tCol, tRow}}
 }
 
-// eraseDrawTargetPoss either erases or draws target positions of Gopher, both the current and the buffered ones.
-func eraseDrawTargetPoss(erase bool) {
-	var img image.Image
-	if erase {
-		img = model.EmptyImg
-	} else {
-		img = model.TargetImg
-	}
-	// dtp: drawTargetPos
-	dtp := func(TargetPos image.Point) {
-		rect := model.TargetImg.Bounds()
-		rect = rect.Add(image.Pt(TargetPos.X-rect.Dx()/2, TargetPos.Y-rect.Dy()/2))
-		draw.Draw(model.LabImg, rect, img, image.Point{}, draw.Over)
-	}
+// stepGopher handles moving the Gopher of the specified player and also handles its multiple target positions.
+func (e *engine) stepGopher(i int) {
+	p := e.g.Players[i]
 
-	dtp(model.Gopher.TargetPos)
-	for _, TargetPos := range model.TargetPoss {
-		dtp(TargetPos)
+	if !p.Racing() {
+		return
 	}
-}
-
-// stepGopher handles moving the Gopher and also handles the multiple target positions of Gopher.
-func stepGopher() {
-	Gopher := model.Gopher
 
-	if model.Dead {
-		Gopher.DrawWithImg(model.DeadImg)
-		return
+	ks := &e.keys[i]
+	reached := func() bool {
+		return int(p.Pos.X) == p.TargetPos.X && int(p.Pos.Y) == p.TargetPos.Y
 	}
 
 	// Check if reached current target position:
-	if int(Gopher.Pos.X) == Gopher.TargetPos.X && int(Gopher.Pos.Y) == Gopher.TargetPos.Y {
+	if reached() {
 		// Check if we have more target positions in our path:
-		if len(model.TargetPoss) > 0 {
+		if len(p.TargetPoss) > 0 {
 			// Set the next target as the current
-			Gopher.TargetPos = model.TargetPoss[0]
+			p.TargetPos = p.TargetPoss[0]
 			// and remove it from the targets:
-			model.TargetPoss = model.TargetPoss[:copy(model.TargetPoss, model.TargetPoss[1:])]
+			p.TargetPoss = p.TargetPoss[:copy(p.TargetPoss, p.TargetPoss[1:])]
+			ks.waypoint = true
+		} else {
+			e.stepGopherByKeys(i)
 		}
 	}
 
 	// Step Gopher
-	stepMovingObj(Gopher)
+	moving := !reached()
+	e.stepMovingObj(p.MovingObj)
+
+	if moving && reached() && ks.waypoint {
+		ks.waypoint = false
+		e.publish(model.Event{Type: model.EventTargetReached, Player: i, Block: p.Block()})
+	}
 }
 
-// stepBulldogs iterates over all Bulldogs, generates new random target if they reached their current, and steps them.
-func stepBulldogs() {
-	// Gopher's position:
-	gpos := model.Gopher.Pos
+// stepBulldogs iterates over all Bulldogs, asks their Behavior for a new target if they reached their current, and steps them.
+func (e *engine) stepBulldogs() {
+	g := e.g
 
-	for _, bd := range model.Bulldogs {
+	for j, bd := range g.Bulldogs {
 		x, y := int(bd.Pos.X), int(bd.Pos.Y)
 
 		if bd.TargetPos.X == x && bd.TargetPos.Y == y {
-			row, col := y/model.BlockSize, x/model.BlockSize
-			// Generate new, random target.
-			// For this we shuffle all the directions, and check them sequentially.
-			// Firts one in which direction there is a free path wins (such path surely exists).
-
-			// Shuffle the directions slice:
-			for i := len(directions) - 1; i > 0; i-- { // last is already random, no use switching with itself
-				r := rand.Intn(i + 1)
-				directions[i], directions[r] = directions[r], directions[i]
-			}
+			bd.TargetPos = model.BlockCenter(bd.Behavior.Next(g, bd))
+		}
 
-			var drow, dcol int
-			for _, dir := range directions {
-				switch dir {
-				case model.DirLeft:
-					dcol = -1
-				case model.DirRight:
-					dcol = 1
-				case model.DirUp:
-					drow = -1
-				case model.DirDown:
-					drow = 1
-				}
-				if model.Lab[row+drow][col+dcol] == model.BlockEmpty {
-					// Direction is good, check if we can even step 2 bocks in this way:
-					if model.Lab[row+drow*2][col+dcol*2] == model.BlockEmpty {
-						drow *= 2
-						dcol *= 2
-					}
-					break
+		e.stepMovingObj(bd.MovingObj)
+
+		block := image.Pt(int(bd.Pos.X)/model.BlockSize, int(bd.Pos.Y)/model.BlockSize)
+		for i, p := range g.Players {
+			// Check if this Bulldog spotted a Gopher
+			if bit := 1 << uint(i); p.Racing() && model.InSight(g.Lab, block, p.Block()) {
+				if e.spotted[j]&bit == 0 {
+					e.spotted[j] |= bit
+					e.publish(model.Event{Type: model.EventBulldogSpotted, Player: i, Bulldog: j, Block: p.Block()})
 				}
-				drow, dcol = 0, 0
+			} else {
+				e.spotted[j] &^= bit
 			}
 
-			bd.TargetPos.X += dcol * model.BlockSize
-			bd.TargetPos.Y += drow * model.BlockSize
-		}
-
-		stepMovingObj(bd)
-
-		if !model.Dead {
-			// Check if this Bulldog reached Gopher
-			if math.Abs(gpos.X-bd.Pos.X) < model.BlockSize*0.75 && math.Abs(gpos.Y-bd.Pos.Y) < model.BlockSize*0.75 {
-				handleDying()
+			// Check if this Bulldog reached a Gopher
+			if p.Racing() && math.Abs(p.Pos.X-bd.Pos.X) < model.BlockSize*0.75 && math.Abs(p.Pos.Y-bd.Pos.Y) < model.BlockSize*0.75 {
+				e.handleDying(i, j)
 			}
 		}
 	}
 }
 
-// handleDying handles the death of Gopher event.
-func handleDying() {
-	model.Dead = true
+// handleDying handles the death of a Gopher event: the Gopher of player i was caught by Bulldog j.
+func (e *engine) handleDying(i, j int) {
+	p := e.g.Players[i]
+	p.Dead = true
+	e.publish(model.Event{Type: model.EventGopherDied, Player: i, Bulldog: j, Block: p.Block()})
+}
+
+// handleLeave handles the player i leaving the game: its Gopher forfeits the race.
+func (e *engine) handleLeave(i int) {
+	g := e.g
+	if i >= len(g.Players) || !g.Players[i].Racing() {
+		return
+	}
+	p := g.Players[i]
+	p.Forfeited = true
+	p.TargetPoss = p.TargetPoss[:0]
 }
 
-// handleWinning handles the winning of game event.
-func handleWinning() {
-	model.Won = true
+// handleWinning handles the winning of game event (or the end of a hunt).
+// In hunt mode the lines of the hunt result are prepared, in multiplayer mode the lines of the race results,
+// in campaign mode the lines of the level complete screen.
+func (e *engine) handleWinning() {
+	g := e.g
 
-	r := model.WonImg.Bounds()
-	r = r.Add(image.Point{view.Pos.X + view.ViewWidth/2 - r.Dx()/2, view.Pos.Y + view.ViewHeight/2 - r.Dy()/2})
-	draw.Draw(model.LabImg, r, model.WonImg, image.Point{}, draw.Over)
+	g.Won = true
+	e.saveRecording()
+
+	g.WonLines = nil
+	if g.Hunting {
+		g.WonLines = g.HuntResult()
+	}
+	if len(g.Players) > 1 && g.Finished() {
+		if g.WonLines != nil {
+			g.WonLines = append(g.WonLines, "")
+		}
+		g.WonLines = append(g.WonLines, g.RaceResults()...)
+	}
+	if len(g.Campaign) > 0 {
+		if g.WonLines != nil {
+			g.WonLines = append(g.WonLines, "")
+		}
+		g.WonLines = append(g.WonLines, g.LevelCompleted()...)
+	}
 }
 
-// stepMovingObj steps the specified MovingObj and draws its image to its new position onto the LabImg.
-func stepMovingObj(m *model.MovingObj) {
+// stepMovingObj steps the specified MovingObj.
+func (e *engine) stepMovingObj(m *model.MovingObj) {
 	x, y := int(m.Pos.X), int(m.Pos.Y)
+	v := e.g.V
 
 	// Only horizontal or vertical movement is allowed!
 	if x != m.TargetPos.X {
-		dx := math.Min(dt*model.V, math.Abs(float64(m.TargetPos.X)-m.Pos.X))
+		dx := math.Min(e.dt*v, math.Abs(float64(m.TargetPos.X)-m.Pos.X))
 		if x > m.TargetPos.X {
 			dx = -dx
 			m.Direction = model.DirLeft
@@ -297,7 +615,7 @@ func stepMovingObj(m *model.MovingObj) {
 		}
 		m.Pos.X += dx
 	} else if y != m.TargetPos.Y {
-		dy := math.Min(dt*model.V, math.Abs(float64(m.TargetPos.Y)-m.Pos.Y))
+		dy := math.Min(e.dt*v, math.Abs(float64(m.TargetPos.Y)-m.Pos.Y))
 		if y > m.TargetPos.Y {
 			dy = -dy
 			m.Direction = model.DirUp
@@ -306,17 +624,4 @@ func stepMovingObj(m *model.MovingObj) {
 		}
 		m.Pos.Y += dy
 	}
-
-	// Draw image at new position
-	m.DrawImg()
-}
-
-// directions is a reused slice of all directions
-var directions = make([]model.Dir, model.DirLength)
-
-func init() {
-	// Populate the directions slice
-	for i := model.Dir(0); i < model.DirLength; i++ {
-		directions[i] = i
-	}
 }
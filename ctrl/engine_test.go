@@ -0,0 +1,161 @@
+package ctrl
+
+import (
+	"github.com/gophergala/golab/model"
+	"image"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// newTestHeadless returns a new headless engine with a game of the specified configuration.
+func newTestHeadless(rows, cols int, bulldogDensity float64) *Headless {
+	h := NewHeadless()
+	g := h.Game()
+	g.Rows, g.Cols = rows, cols
+	g.LabWidth, g.LabHeight = cols*model.BlockSize, rows*model.BlockSize
+	g.BulldogDensity = bulldogDensity
+	g.V = model.BlockSize * 2.0
+	g.BulldogBehaviors = []string{"random", "chase", "pursuit"}
+	return h
+}
+
+func TestPathFindingClickLimit(t *testing.T) {
+	h := newTestHeadless(51, 51, 0)
+	h.Reset(model.NewGame{Algorithm: "backtracker", Seed: 1})
+	g := h.Game()
+
+	exit := g.CurLevel.Exit
+	corners := model.PathCorners(model.ShortestPath(g.Lab, g.CurLevel.Start, exit))
+	p := g.Players[0]
+	if len(corners) <= cap(p.TargetPoss) {
+		t.Fatalf("path has only %d corners, the test needs a longer one", len(corners))
+	}
+
+	sub := g.Events.Subscribe(10)
+	defer sub.Close()
+	h.Input(model.Input{Click: &model.Click{X: exit.X * model.BlockSize, Y: exit.Y * model.BlockSize, PathFinding: true}})
+
+	if len(p.TargetPoss) != 0 || cap(p.TargetPoss) != 20 {
+		t.Errorf("targets were queued: %d (capacity %d)", len(p.TargetPoss), cap(p.TargetPoss))
+	}
+	if ev := <-sub.C; ev.Type != model.EventWaypointRejected {
+		t.Errorf("got %v event, want %v", ev.Type, model.EventWaypointRejected)
+	}
+
+	// A short path fits
+	h.Input(model.Input{Click: &model.Click{X: corners[0].X * model.BlockSize, Y: corners[0].Y * model.BlockSize, PathFinding: true}})
+	if len(p.TargetPoss) != 1 {
+		t.Errorf("got %d targets, want 1", len(p.TargetPoss))
+	}
+	if ev := <-sub.C; ev.Type != model.EventWaypointAccepted {
+		t.Errorf("got %v event, want %v", ev.Type, model.EventWaypointAccepted)
+	}
+}
+
+// testInputs returns inputs stamped with the tick they are to be handled at: waypoints to random free blocks
+// and direction key events.
+func testInputs(lab [][]model.Block, seed int64) []model.Input {
+	var inputs []model.Input
+	r := rand.New(rand.NewSource(seed))
+	for tick := int64(10); tick < 3000; tick += 10 + r.Int63n(100) {
+		if r.Intn(3) == 0 {
+			inputs = append(inputs, model.Input{Tick: tick, Key: &model.Key{Dir: model.Dir(r.Intn(int(model.DirLength))), Pressed: r.Intn(2) == 0}})
+			continue
+		}
+		row, col := r.Intn(len(lab)), r.Intn(len(lab[0]))
+		if lab[row][col] == model.BlockWall {
+			continue
+		}
+		c := model.BlockCenter(image.Pt(col, row))
+		inputs = append(inputs, model.Input{Tick: tick, Click: &model.Click{X: c.X, Y: c.Y, PathFinding: true}})
+	}
+	return inputs
+}
+
+// run plays a game with the specified inputs, and returns the positions of the Gophers and the Bulldogs
+// after each tick, and the final state of the game.
+func run(ng model.NewGame, inputs []model.Input) (positions []image.Point, final *model.State) {
+	h := newTestHeadless(21, 21, 5)
+	h.Reset(ng)
+	g := h.Game()
+	for tick := int64(0); tick < 3000; tick++ {
+		for len(inputs) > 0 && inputs[0].Tick == g.Tick {
+			h.Input(inputs[0])
+			inputs = inputs[1:]
+		}
+		more := h.Tick()
+		for _, p := range g.Players {
+			positions = append(positions, image.Pt(int(p.Pos.X*1000), int(p.Pos.Y*1000)))
+		}
+		for _, bd := range g.Bulldogs {
+			positions = append(positions, image.Pt(int(bd.Pos.X*1000), int(bd.Pos.Y*1000)))
+		}
+		if !more {
+			break
+		}
+	}
+	return positions, g.TakeSnapshot().State(0, false)
+}
+
+func TestDeterminism(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		ng := model.NewGame{Algorithm: model.Generators[int(seed)%len(model.Generators)].Name(), Seed: seed}
+
+		h := newTestHeadless(21, 21, 5)
+		h.Reset(ng)
+		inputs := testInputs(h.Game().Lab, seed)
+
+		pos1, final1 := run(ng, inputs)
+		pos2, final2 := run(ng, inputs)
+		if !reflect.DeepEqual(pos1, pos2) {
+			t.Errorf("seed %d: positions differ", seed)
+		}
+		if !reflect.DeepEqual(final1, final2) {
+			t.Errorf("seed %d: outcomes differ: %+v, %+v", seed, final1, final2)
+		}
+	}
+}
+
+func TestLeave(t *testing.T) {
+	h := newTestHeadless(9, 9, 0)
+	g := h.Game()
+	g.Joined = 2
+	h.Reset(model.NewGame{Algorithm: "backtracker", Seed: 1})
+
+	exit := model.BlockCenter(g.CurLevel.Exit)
+	h.Input(model.Input{Click: &model.Click{X: exit.X, Y: exit.Y, PathFinding: true}})
+	g.Leave(1)
+	if g.Joined != 1 {
+		t.Errorf("got %d joined players, want 1", g.Joined)
+	}
+
+	// The race must finish without the player who left
+	for i := 0; i < 10000 && h.Tick(); i++ {
+	}
+	if !g.Won {
+		t.Fatal("race did not finish")
+	}
+	if p := g.Players[1]; !p.Forfeited || p.Finished {
+		t.Errorf("player who left did not forfeit: %+v", p)
+	}
+
+	// The leave is recorded, so the replay gives the same outcome
+	rp := h.Replay()
+	if n := len(rp.Inputs); n != 2 || rp.Inputs[1].Leave == nil || rp.Inputs[1].Leave.Player != 1 {
+		t.Fatalf("leave is not recorded: %+v", rp.Inputs)
+	}
+	h2 := newTestHeadless(9, 9, 0)
+	h2.Game().Joined = 2
+	h2.Reset(model.NewGame{Algorithm: "backtracker", Seed: 1})
+	inputs := rp.Inputs
+	for h2.Game().Tick < rp.Ticks {
+		for ; len(inputs) > 0 && inputs[0].Tick == h2.Game().Tick; inputs = inputs[1:] {
+			h2.Input(inputs[0])
+		}
+		h2.Tick()
+	}
+	if g2 := h2.Game(); !g2.Won || !g2.Players[1].Forfeited || !reflect.DeepEqual(g2.RaceResults(), g.RaceResults()) {
+		t.Errorf("replay differs: %v, %v", g2.RaceResults(), g.RaceResults())
+	}
+}
@@ -0,0 +1,64 @@
+package ctrl
+
+import (
+	"github.com/gophergala/golab/model"
+)
+
+// Headless is a game engine without its own goroutine and without rendering:
+// the game is stepped tick by tick by the caller, using the same game logic as the engines of the web UI.
+// It's meant to run the game rules in-process, e.g. in reinforcement learning environments.
+//
+// Headless is not safe for concurrent use, the Mutex of its game is not used.
+type Headless struct {
+	e *engine
+}
+
+// NewHeadless creates a new headless engine with a new game having the default configuration.
+// The configuration of the game may be changed (see Game()) before calling Reset().
+func NewHeadless() *Headless {
+	g := model.CreateGame()
+	g.Headless = true
+
+	e := &engine{g: g, loopDelay: LoopDelay}
+	e.setTickRate(TickRate)
+	return &Headless{e}
+}
+
+// Game returns the game of the engine.
+func (h *Headless) Game() *model.Game {
+	return h.e.g
+}
+
+// SetTickRate sets the number of simulation ticks per second. Must be called before Reset().
+func (h *Headless) SetTickRate(rate int) {
+	h.e.setTickRate(rate)
+}
+
+// Reset starts a new game with the specified parameters.
+func (h *Headless) Reset(ng model.NewGame) {
+	h.e.initNew(ng)
+}
+
+// Input handles an input of a player. The input takes effect in the next tick, just like in the web UI.
+func (h *Headless) Input(in model.Input) {
+	h.e.handleInput(in)
+}
+
+// Tick performs a simulation tick, unless the game is over.
+// Returns false if the game is over.
+func (h *Headless) Tick() bool {
+	if h.e.g.Won {
+		return false
+	}
+	h.e.tick()
+	return !h.e.g.Won
+}
+
+// Replay returns the replay of the current game (up to the current tick),
+// which can be played back in the web UI.
+func (h *Headless) Replay() *model.Replay {
+	g := h.e.g
+	rp := *g.Recording
+	rp.Ticks = g.Tick
+	return &rp
+}
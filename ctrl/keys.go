@@ -0,0 +1,143 @@
+package ctrl
+
+import (
+	"github.com/gophergala/golab/model"
+	"image"
+)
+
+// keys is the keyboard control state of a player.
+//
+// While a direction key is held, Gopher moves continuously from block to block.
+// A newly pressed direction is remembered as the next turn: Gopher turns at the first block where it's possible,
+// and keeps going in its current direction until then. When all keys are released, Gopher stops at the next block.
+type keys struct {
+	// pressedDirs are the currently held direction keys
+	pressedDirs []model.Dir
+
+	// turnDir is the direction to turn to as soon as possible, valid if turnPending is true
+	turnDir     model.Dir
+	turnPending bool
+
+	// keyMoving tells if Gopher is moving by keyboard control
+	keyMoving bool
+
+	// waypoint tells if the current target of Gopher is a queued target (not one set by keyboard control)
+	waypoint bool
+}
+
+// resetKeys resets the keyboard control state of all players.
+func (e *engine) resetKeys() {
+	if len(e.keys) < len(e.g.Players) {
+		e.keys = make([]keys, len(e.g.Players))
+	}
+	for i := range e.keys {
+		ks := &e.keys[i]
+		ks.pressedDirs = ks.pressedDirs[:0]
+		ks.turnPending = false
+		ks.keyMoving = false
+		ks.waypoint = false
+	}
+}
+
+// handleKey handles a key event.
+func (e *engine) handleKey(k model.Key) {
+	g := e.g
+
+	if k.Player >= len(g.Players) || !g.Players[k.Player].Racing() || g.Paused {
+		return
+	}
+	p, ks := g.Players[k.Player], &e.keys[k.Player]
+
+	// Remove it from the pressed ones (if key press is repeated or release)
+	for i, d := range ks.pressedDirs {
+		if d == k.Dir {
+			ks.pressedDirs = append(ks.pressedDirs[:i], ks.pressedDirs[i+1:]...)
+			break
+		}
+	}
+
+	if !k.Pressed {
+		if len(ks.pressedDirs) == 0 {
+			// Stop at the current target (which is the next block)
+			ks.turnPending = false
+			ks.keyMoving = false
+		}
+		return
+	}
+
+	ks.pressedDirs = append(ks.pressedDirs, k.Dir)
+	ks.turnDir, ks.turnPending = k.Dir, true
+
+	// Keyboard takes over: throw away queued targets
+	Gopher := p.MovingObj
+	p.TargetPoss = p.TargetPoss[0:0]
+	ks.waypoint = false
+
+	pos := image.Pt(int(Gopher.Pos.X), int(Gopher.Pos.Y))
+	if pos == Gopher.TargetPos {
+		return // Not moving, will be handled in stepGopher()
+	}
+
+	// Gopher is moving: shorten the current target to the next block center
+	// so Gopher can turn (or stop) there. If the new direction is the opposite, reverse right away.
+	ks.keyMoving = true
+	dir := Gopher.Direction
+	if k.Dir == dir.Opposite() {
+		dir = k.Dir
+		ks.turnPending = false
+	}
+	d := dir.Delta()
+	if d.X != 0 {
+		Gopher.TargetPos.X = centerAhead(Gopher.Pos.X, d.X)
+	} else {
+		Gopher.TargetPos.Y = centerAhead(Gopher.Pos.Y, d.Y)
+	}
+}
+
+// centerAhead returns the coordinate of the closest block center from the coordinate x (inclusive)
+// in the direction specified by sign (1 or -1).
+func centerAhead(x float64, sign int) int {
+	c := int(x)/model.BlockSize*model.BlockSize + model.BlockSize/2
+	if sign > 0 && int(x) > c {
+		c += model.BlockSize
+	} else if sign < 0 && int(x) < c {
+		c -= model.BlockSize
+	}
+	return c
+}
+
+// stepGopherByKeys sets the next target of the Gopher of the specified player according to its keyboard control state.
+// Must only be called when the Gopher reached its target and there are no queued targets.
+func (e *engine) stepGopherByKeys(i int) {
+	ks := &e.keys[i]
+	if len(ks.pressedDirs) == 0 {
+		return
+	}
+
+	g := e.g
+	Gopher := g.Players[i].MovingObj
+	block := image.Pt(Gopher.TargetPos.X/model.BlockSize, Gopher.TargetPos.Y/model.BlockSize)
+	free := func(d model.Dir) bool {
+		p := block.Add(d.Delta())
+		return g.Lab[p.Y][p.X] == model.BlockEmpty
+	}
+	moveTo := func(d model.Dir) {
+		Gopher.TargetPos = model.BlockCenter(block.Add(d.Delta()))
+		ks.keyMoving = true
+	}
+
+	switch {
+	case ks.turnPending && free(ks.turnDir):
+		moveTo(ks.turnDir)
+		ks.turnPending = false
+	case ks.keyMoving && free(Gopher.Direction):
+		moveTo(Gopher.Direction)
+	default:
+		ks.keyMoving = false
+		if ks.turnPending {
+			// Can't go that way, at least face that direction
+			Gopher.Direction = ks.turnDir
+			ks.turnPending = false
+		}
+	}
+}
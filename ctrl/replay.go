@@ -0,0 +1,195 @@
+package ctrl
+
+import (
+	"fmt"
+	"github.com/gophergala/golab/model"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RecordDir is the directory to save the replays of the games to. Empty means replays are not saved.
+var RecordDir string
+
+// seekChunk is the maximum number of ticks simulated in one iteration of the main loop while seeking in a replay,
+// so the Mutex of the game is released regularly even when seeking far.
+const seekChunk = 1000
+
+// replayState is the replay state of an engine.
+type replayState struct {
+	// recSaved tells if the recording of the current game has been saved
+	recSaved bool
+
+	// playbackGame is the parameters of the game of the replay being played back
+	playbackGame model.NewGame
+
+	// nextInput is the index of the next input to apply from the replay being played back
+	nextInput int
+
+	// seeking tells if seeking is in progress: ticks are re-simulated up to seekTo
+	seeking bool
+
+	// seekTo is the tick being seeked to
+	seekTo int64
+}
+
+// startRecording starts recording the current game (unless a replay is being played back).
+// Must be called right after Game.InitNew().
+func (e *engine) startRecording() {
+	g := e.g
+	if g.Playback != nil {
+		return
+	}
+
+	g.Recording = g.NewReplay()
+	g.Recording.LoopDelay = e.loopDelay
+	g.Recording.TickRate = e.tickRate
+	e.recSaved = false
+}
+
+// saveRecording saves the recording of the current game to a new file in RecordDir,
+// if saving is enabled and the recording is not empty and has not been saved yet.
+func (e *engine) saveRecording() {
+	g := e.g
+	rec := g.Recording
+	if RecordDir == "" || rec == nil || e.recSaved || g.Tick == 0 {
+		return
+	}
+	e.recSaved = true
+
+	rec.Ticks = g.Tick
+	name := filepath.Join(RecordDir, fmt.Sprintf("golab-%s-%d-%s.replay",
+		rec.Game.Algorithm, rec.Game.Seed, time.Now().Format("20060102-150405.000")))
+
+	f, err := os.Create(name)
+	if err != nil {
+		log.Println("Failed to save replay:", err)
+		return
+	}
+	defer f.Close()
+
+	if err := rec.Write(f); err != nil {
+		log.Println("Failed to save replay:", err)
+	}
+}
+
+// handleInput handles an input of the player, and records it if the game is being recorded.
+func (e *engine) handleInput(in model.Input) {
+	g := e.g
+	if g.Recording != nil {
+		in.Tick = g.Tick
+		g.Recording.Inputs = append(g.Recording.Inputs, in)
+	}
+
+	e.applyInput(in)
+}
+
+// applyInput applies an input of a player (a recorded or a replayed one).
+func (e *engine) applyInput(in model.Input) {
+	switch {
+	case in.Click != nil:
+		e.handleClick(*in.Click)
+	case in.Key != nil:
+		e.handleKey(*in.Key)
+	default:
+		e.handleLeave(in.Leave.Player)
+	}
+}
+
+// handleReplayCmd handles a replay command.
+func (e *engine) handleReplayCmd(cmd model.ReplayCmd) {
+	if cmd.Replay != nil {
+		e.startPlayback(cmd.Replay)
+		return
+	}
+
+	if e.g.Playback == nil {
+		return // Not in replay mode
+	}
+	e.seek(cmd.Seek)
+}
+
+// startPlayback starts playing back the specified replay.
+func (e *engine) startPlayback(rp *model.Replay) {
+	g := e.g
+
+	e.saveRecording()
+	g.Recording = nil
+
+	g.Playback = rp
+	e.seeking = false
+	e.playbackGame = g.ApplyReplay(rp)
+	e.loopDelay = rp.LoopDelay
+	e.setTickRate(rp.TickRate)
+
+	e.initNew(e.playbackGame)
+	e.nextInput = 0
+}
+
+// stopPlayback stops playing back the replay and restores the default configuration, if in replay mode.
+func (e *engine) stopPlayback() {
+	g := e.g
+	if g.Playback == nil {
+		return
+	}
+
+	g.Playback = nil
+	e.seeking = false
+	g.ResetConfig()
+	e.loopDelay = LoopDelay
+	e.setTickRate(TickRate)
+}
+
+// seek starts seeking to the specified tick in the replay being played back.
+// Seeking backward restarts the game of the replay, then the ticks up to the specified tick are simulated
+// by seekStep in the following iterations of the main loop.
+func (e *engine) seek(t int64) {
+	g := e.g
+
+	if t < 0 {
+		t = 0
+	}
+	if t > g.Playback.Ticks {
+		t = g.Playback.Ticks
+	}
+
+	e.seeking, e.seekTo = true, t
+	if t < g.Tick {
+		e.initNew(e.playbackGame)
+		e.nextInput = 0
+	}
+}
+
+// seekStep simulates the next chunk of the ticks being seeked (at most seekChunk ticks), and ends seeking
+// when the target tick is reached.
+func (e *engine) seekStep() {
+	g := e.g
+
+	// Inputs are only handled when not paused
+	paused := g.Paused
+	g.Paused = false
+	for n := 0; n < seekChunk && g.Tick < e.seekTo && !g.Won; n++ {
+		e.tick()
+	}
+	g.Paused = paused
+
+	if g.Tick >= e.seekTo || g.Won {
+		e.seeking = false
+	}
+}
+
+// playbackEnded tells if a replay is being played back and its end has been reached.
+func (e *engine) playbackEnded() bool {
+	g := e.g
+	return g.Playback != nil && g.Tick >= g.Playback.Ticks
+}
+
+// applyReplayInputs applies the inputs of the replay being played back which are due at the current tick.
+func (e *engine) applyReplayInputs() {
+	g := e.g
+	inputs := g.Playback.Inputs
+	for ; e.nextInput < len(inputs) && inputs[e.nextInput].Tick <= g.Tick; e.nextInput++ {
+		e.applyInput(inputs[e.nextInput])
+	}
+}
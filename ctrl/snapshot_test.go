@@ -0,0 +1,48 @@
+package ctrl
+
+import (
+	"github.com/gophergala/golab/model"
+	"sync"
+	"testing"
+)
+
+// TestSnapshotConcurrency reads the snapshots of a game while its engine is running.
+// Run it with the race detector (go test -race) to check that readers never access the state of the engine.
+func TestSnapshotConcurrency(t *testing.T) {
+	// The configuration of new games is the package level defaults (normally set by flags)
+	rows, cols, width, height := model.Rows, model.Cols, model.LabWidth, model.LabHeight
+	v, density, delay := model.V, model.BulldogDensity, LoopDelay
+	defer func() {
+		model.Rows, model.Cols, model.LabWidth, model.LabHeight = rows, cols, width, height
+		model.V, model.BulldogDensity, LoopDelay = v, density, delay
+	}()
+	model.Rows, model.Cols, model.V, model.BulldogDensity, LoopDelay = 21, 21, model.BlockSize*2.0, 10, 10
+	model.LabWidth, model.LabHeight = model.Cols*model.BlockSize, model.Rows*model.BlockSize
+
+	g := StartEngine()
+	defer close(g.Quit)
+	g.NewGameCh <- model.NewGame{Algorithm: "backtracker", Seed: 1}
+
+	const frames = 30
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for snap := g.Snapshot(); snap.Frame < frames; snap = g.Snapshot() {
+				img := snap.Compose(snap.LabImg.Bounds())
+				if img.Bounds() != snap.LabImg.Bounds() {
+					t.Errorf("got image of %v, want %v", img.Bounds(), snap.LabImg.Bounds())
+					return
+				}
+				snap.State(0, false)
+				snap.SceneFrame()
+			}
+		}()
+	}
+
+	// Keep the Gopher moving meanwhile
+	exit := model.BlockCenter(g.Snapshot().CurLevel.Exit)
+	g.ClickCh <- model.Click{X: exit.X, Y: exit.Y, PathFinding: true}
+	wg.Wait()
+}
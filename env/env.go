@@ -0,0 +1,239 @@
+/*
+Package env is a headless, in-process environment of GoLab for reinforcement learning.
+
+The environment runs the rules of the game with no HTTP server and no image rendering,
+using the same game logic as the web UI (see ctrl.Headless), so trained agents behave identically in the real game.
+Observations are of the same type as the state served by the JSON bot API (model.State),
+and actions are direction key events, so a trained agent can play the real game through the client package.
+
+A simple episode:
+
+	e, err := env.New(env.DefaultConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	obs := e.Reset(42)
+	for done := false; !done; {
+		var reward float64
+		obs, reward, done = e.Step(agent.Act(obs))
+		agent.Learn(reward)
+	}
+
+Env is not safe for concurrent use, but multiple Envs can be run in parallel.
+*/
+package env
+
+import (
+	"fmt"
+	"github.com/gophergala/golab/ctrl"
+	"github.com/gophergala/golab/model"
+)
+
+// Action is an action of the agent: the direction key to hold during a step (or none).
+// Holding a direction key moves Gopher continuously in that direction, just like in the web UI.
+type Action int
+
+// Actions of the agent.
+const (
+	// ActionStop releases the held direction key: Gopher stops at the next block
+	ActionStop Action = iota
+	ActionRight
+	ActionLeft
+	ActionUp
+	ActionDown
+
+	// NumActions is the number of actions
+	NumActions = iota
+)
+
+// dir returns the direction of the direction key of the action, valid if the action is not ActionStop.
+func (a Action) dir() model.Dir {
+	return model.Dir(a - ActionRight)
+}
+
+// Config is the configuration of an environment.
+type Config struct {
+	// Configuration of the game, see the command line flags of GoLab
+	Rows, Cols     int
+	BulldogDensity float64
+	V              float64
+	Braid          float64
+	Algorithm      string
+	Behaviors      []string
+	TickRate       int
+
+	// TicksPerStep is the number of simulation ticks a step advances the game
+	TicksPerStep int
+
+	// MaxSteps is the maximum number of steps of an episode, 0 means no limit
+	MaxSteps int
+
+	// Rewards: ProgressReward is given for each block Gopher gets closer to the exit (along the shortest path),
+	// DeathPenalty is subtracted if Gopher dies, WinBonus is given if Gopher reaches the exit.
+	ProgressReward float64
+	DeathPenalty   float64
+	WinBonus       float64
+}
+
+// DefaultConfig is the default configuration of environments, matching the defaults of the web UI.
+var DefaultConfig = Config{
+	Rows:           33,
+	Cols:           33,
+	BulldogDensity: 10,
+	V:              model.BlockSize * 2.0,
+	Algorithm:      model.Algorithm,
+	Behaviors:      model.BulldogBehaviors,
+	TickRate:       60,
+	TicksPerStep:   10,
+	ProgressReward: 1,
+	DeathPenalty:   10,
+	WinBonus:       10,
+}
+
+// validate validates the configuration.
+// Returns nil if everything is ok, else an error.
+func (c *Config) validate() error {
+	if c.Rows < 9 || c.Rows > 99 || c.Cols < 9 || c.Cols > 99 || c.Rows&0x01 == 0 || c.Cols&0x01 == 0 {
+		return fmt.Errorf("invalid size: %dx%d", c.Rows, c.Cols)
+	}
+	if c.BulldogDensity < 0 || c.BulldogDensity > 50 {
+		return fmt.Errorf("bulldogs %f is outside of valid range", c.BulldogDensity)
+	}
+	if c.V < 20 || c.V > 200 {
+		return fmt.Errorf("v %f is outside of valid range", c.V)
+	}
+	if c.Braid < 0 || c.Braid > 1 {
+		return fmt.Errorf("braid %f is outside of valid range", c.Braid)
+	}
+	if model.GeneratorByName(c.Algorithm) == nil {
+		return fmt.Errorf("unknown algorithm: %s", c.Algorithm)
+	}
+	if len(c.Behaviors) == 0 {
+		return fmt.Errorf("no behaviors")
+	}
+	for _, name := range c.Behaviors {
+		if model.NewBehavior(name) == nil {
+			return fmt.Errorf("unknown behavior: %s", name)
+		}
+	}
+	if c.TickRate < 10 || c.TickRate > 200 {
+		return fmt.Errorf("tickRate %d is outside of valid range", c.TickRate)
+	}
+	if c.TicksPerStep < 1 {
+		return fmt.Errorf("ticksPerStep %d must be positive", c.TicksPerStep)
+	}
+	return nil
+}
+
+// Env is a reinforcement learning environment: a headless game of a single player controlling Gopher.
+type Env struct {
+	// Config is the configuration of the environment
+	Config
+
+	// h is the headless engine running the game
+	h *ctrl.Headless
+
+	// held is the currently held direction key (ActionStop if none)
+	held Action
+
+	// dist is the length of the shortest path from Gopher to the exit in blocks at the end of the last step
+	dist int
+
+	// steps is the number of steps in the current episode
+	steps int
+}
+
+// New creates a new environment with the specified configuration.
+// Reset() must be called to start the first episode.
+func New(c Config) (*Env, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	h := ctrl.NewHeadless()
+	h.SetTickRate(c.TickRate)
+
+	g := h.Game()
+	g.Rows, g.Cols = c.Rows, c.Cols
+	g.LabWidth, g.LabHeight = c.Cols*model.BlockSize, c.Rows*model.BlockSize
+	g.BulldogDensity, g.V, g.Braid = c.BulldogDensity, c.V, c.Braid
+	g.BulldogBehaviors = c.Behaviors
+	g.FixedLevel, g.Campaign = nil, nil
+
+	return &Env{Config: c, h: h}, nil
+}
+
+// Reset starts a new episode: a new game with the specified seed (0 means a random seed).
+// Returns the initial observation.
+func (e *Env) Reset(seed int64) *model.State {
+	e.h.Reset(model.NewGame{Algorithm: e.Algorithm, Seed: seed})
+	e.held = ActionStop
+	e.steps = 0
+	e.dist = e.exitDist()
+	return e.observe()
+}
+
+// Step performs the specified action and advances the game by TicksPerStep ticks.
+// Returns the observation, the reward and whether the episode is done
+// (Gopher reached the exit, Gopher died or MaxSteps is reached).
+func (e *Env) Step(a Action) (obs *model.State, reward float64, done bool) {
+	e.act(a)
+
+	g := e.h.Game()
+	p := g.Players[0]
+	for i := 0; i < e.TicksPerStep && !p.Dead; i++ {
+		if !e.h.Tick() {
+			break // Won
+		}
+	}
+	e.steps++
+
+	dist := e.exitDist()
+	reward = float64(e.dist-dist) * e.ProgressReward
+	e.dist = dist
+
+	switch {
+	case p.Dead:
+		reward -= e.DeathPenalty
+		done = true
+	case g.Won:
+		reward += e.WinBonus
+		done = true
+	case e.MaxSteps > 0 && e.steps >= e.MaxSteps:
+		done = true
+	}
+
+	return e.observe(), reward, done
+}
+
+// act applies the specified action: presses or releases direction keys as needed.
+func (e *Env) act(a Action) {
+	if a == e.held || a < ActionStop || a >= NumActions {
+		return
+	}
+	if e.held != ActionStop {
+		e.h.Input(model.Input{Key: &model.Key{Dir: e.held.dir()}})
+	}
+	if a != ActionStop {
+		e.h.Input(model.Input{Key: &model.Key{Dir: a.dir(), Pressed: true}})
+	}
+	e.held = a
+}
+
+// exitDist returns the length of the shortest path from the block of Gopher to the exit in blocks.
+func (e *Env) exitDist() int {
+	g := e.h.Game()
+	return len(model.ShortestPath(g.Lab, g.Players[0].Block(), g.CurLevel.Exit)) - 1
+}
+
+// observe returns the current observation.
+func (e *Env) observe() *model.State {
+	st := e.h.Game().TakeSnapshot().State(0, false)
+	st.TickRate = e.TickRate
+	return st
+}
+
+// Replay returns the replay of the current episode, which can be played back in the web UI.
+func (e *Env) Replay() *model.Replay {
+	return e.h.Replay()
+}
@@ -0,0 +1,153 @@
+package env
+
+import (
+	"bytes"
+	"github.com/gophergala/golab/ctrl"
+	"github.com/gophergala/golab/model"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// testConfig is the configuration of the test environments.
+var testConfig = Config{
+	Rows:           15,
+	Cols:           15,
+	BulldogDensity: 15,
+	V:              model.BlockSize * 2.0,
+	Algorithm:      "backtracker",
+	Behaviors:      []string{"random", "chase"},
+	TickRate:       60,
+	TicksPerStep:   10,
+	MaxSteps:       300,
+	ProgressReward: 1,
+	DeathPenalty:   10,
+	WinBonus:       10,
+}
+
+// step is a step of an episode: the observation, the reward and whether the episode is done.
+type step struct {
+	obs    *model.State
+	reward float64
+	done   bool
+}
+
+// testActions returns a fixed sequence of actions: random directions held for a random number of steps.
+func testActions(seed int64) []Action {
+	var actions []Action
+	r := rand.New(rand.NewSource(seed))
+	for len(actions) < testConfig.MaxSteps {
+		a := Action(r.Intn(NumActions))
+		for n := 1 + r.Intn(8); n > 0; n-- {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// runEpisode runs an episode with the specified seed and actions, and returns its trajectory and replay.
+func runEpisode(t *testing.T, seed int64, actions []Action) ([]step, *model.Replay) {
+	e, err := New(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	traj := []step{{obs: e.Reset(seed)}}
+	for _, a := range actions {
+		obs, reward, done := e.Step(a)
+		traj = append(traj, step{obs, reward, done})
+		if done {
+			break
+		}
+	}
+	return traj, e.Replay()
+}
+
+func TestDeterministicTrajectory(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		actions := testActions(seed)
+		traj, _ := runEpisode(t, seed, actions)
+		traj2, _ := runEpisode(t, seed, actions)
+		if !reflect.DeepEqual(traj, traj2) {
+			t.Errorf("seed %d: trajectories differ", seed)
+		}
+
+		// Check the reward shaping and the done conditions
+		first, last := traj[0].obs, traj[len(traj)-1].obs
+		exitDist := func(obs *model.State) int {
+			lab := make([][]model.Block, len(obs.Lab))
+			for i, row := range obs.Lab {
+				for _, c := range row {
+					b := model.BlockEmpty
+					if c == model.LevelWall {
+						b = model.BlockWall
+					}
+					lab[i] = append(lab[i], b)
+				}
+			}
+			return len(model.ShortestPath(lab, obs.Gophers[0].Block, obs.Exit)) - 1
+		}
+		want := float64(exitDist(first)-exitDist(last)) * testConfig.ProgressReward
+		switch {
+		case last.Dead:
+			want -= testConfig.DeathPenalty
+		case last.Won:
+			want += testConfig.WinBonus
+		case len(traj)-1 != testConfig.MaxSteps:
+			t.Errorf("seed %d: episode ended after %d steps, neither dead nor won", seed, len(traj)-1)
+		}
+		total := 0.0
+		for i, s := range traj[1:] {
+			total += s.reward
+			if s.done != (i == len(traj)-2) {
+				t.Errorf("seed %d: step %d: done is %v", seed, i+1, s.done)
+			}
+		}
+		if total != want {
+			t.Errorf("seed %d: total reward %v, want %v", seed, total, want)
+		}
+	}
+}
+
+func TestTrajectoryMatchesReplay(t *testing.T) {
+	dead := 0
+	for seed := int64(1); seed <= 5; seed++ {
+		traj, rp := runEpisode(t, seed, testActions(seed))
+
+		// The replay must be valid for the web UI as well
+		var buf bytes.Buffer
+		if err := rp.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		rp, err := model.ReadReplay(&buf)
+		if err != nil {
+			t.Fatalf("seed %d: invalid replay: %v", seed, err)
+		}
+
+		// Play back the inputs of the replay in a headless engine configured from the replay
+		h := ctrl.NewHeadless()
+		h.SetTickRate(rp.TickRate)
+		g := h.Game()
+		h.Reset(g.ApplyReplay(rp))
+		inputs := rp.Inputs
+		for _, s := range traj {
+			for g.Tick < s.obs.Tick {
+				for ; len(inputs) > 0 && inputs[0].Tick == g.Tick; inputs = inputs[1:] {
+					h.Input(inputs[0])
+				}
+				h.Tick()
+			}
+			st := g.TakeSnapshot().State(0, false)
+			st.TickRate = rp.TickRate
+			if !reflect.DeepEqual(st, s.obs) {
+				t.Fatalf("seed %d: state at tick %d differs from the observation:\n%+v\n%+v", seed, s.obs.Tick, st, s.obs)
+			}
+		}
+
+		if traj[len(traj)-1].obs.Dead {
+			dead++
+		}
+	}
+	if dead == 0 || dead == 5 {
+		t.Errorf("all episodes have the same outcome (%d of 5 dead)", dead)
+	}
+}
@@ -12,13 +12,31 @@ import (
 	"github.com/gophergala/golab/view"
 	"log"
 	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
+	"time"
 )
 
 // port tells on which port to open the UI web server
 var port int
 
+// levelFile is the name of the level file to play instead of generated labyrinths
+var levelFile string
+
+// campaign tells if campaign mode is enabled
+var campaign bool
+
+// progression is the progression table of the campaign
+var progression string
+
+// replayFile is the name of the replay file to play back on startup
+var replayFile string
+
+// behaviors is the comma separated list of the behaviors of the Bulldogs
+var behaviors string
+
 // autoOpen tells if the UI web page should be auto-opened in the users's default browser
 var autoOpen bool
 
@@ -34,14 +52,29 @@ func processFlags() error {
 	flag.IntVar(&model.Rows, "rows", 33, "the number of rows in the Labyrinth; must be odd; valid range: 9..99")
 	flag.IntVar(&model.Cols, "cols", 33, "the number of columns in the Labyrinth; must be odd; valid range: 9..99")
 	flag.Float64Var(&model.BulldogDensity, "bulldogs", 10, "the number of Bulldogs in an area of 1,000 Blocks; valid range: 0..50")
+	flag.StringVar(&behaviors, "behaviors", strings.Join(model.BulldogBehaviors, ","), "comma separated list of the behaviors of the Bulldogs, assigned to them in turns; valid values: "+strings.Join(model.BehaviorNames(), ", "))
+	flag.Float64Var(&model.Braid, "braid", 0, "the fraction of dead ends to remove from generated labyrinths (creating loops); valid range: 0..1")
+	flag.BoolVar(&campaign, "campaign", false, "enables campaign mode: multiple levels with escalating difficulty defined by the progression table")
+	flag.StringVar(&progression, "progression", model.DefaultProgression, "the progression table of the campaign, comma separated list of ROWSxCOLS:BULLDOGS:V levels")
+	flag.StringVar(&levelFile, "level", "", "the level file to play instead of generated labyrinths; overrides rows and cols")
+	flag.Int64Var(&model.Seed, "seed", 0, "the seed of the random number generator of the games; 0 means a random seed for each game")
+	flag.DurationVar(&model.HuntTime, "huntTime", model.HuntTime, "time limit of hunts (when a player hunts the Gophers as a Bulldog); valid range: 10s..1h")
+	flag.StringVar(&model.Algorithm, "algorithm", model.Algorithm, "the labyrinth generator algorithm; valid values: "+strings.Join(model.GeneratorNames(), ", "))
 
 	// Control/Engine flags
 	flag.IntVar(&ctrl.LoopDelay, "loopDelay", 50, "loop delay of the game engine, in milliseconds; valid range: 10..100")
+	flag.IntVar(&ctrl.TickRate, "tickRate", 60, "number of simulation ticks per second of the game engine; valid range: 10..200")
+	flag.StringVar(&ctrl.RecordDir, "recordDir", "", "the directory to save the replays of the games to; empty means replays are not saved")
+	flag.StringVar(&replayFile, "replay", "", "the replay file to play back in the game of each new browser session")
 	flag.Float64Var(&model.V, "v", model.BlockSize*2.0, "moving speed of Gopher and the Bulldogs in pixel/sec; valid range: 20..200")
 
 	// View package flags
 	flag.IntVar(&view.ViewWidth, "viewWidth", 700, "width of the view image in pixels in the UI web page; valid range: 150..2000")
 	flag.IntVar(&view.ViewHeight, "viewHeight", 700, "height of the view image in pixels in the UI web page; valid range: 150..2000")
+	flag.DurationVar(&view.SessionTimeout, "sessionTimeout", view.SessionTimeout, "idle time after which a browser session and its game are discarded; valid range: 1m..24h")
+	flag.IntVar(&view.MaxGames, "maxGames", view.MaxGames, "the maximum number of running games of browser sessions, no new sessions are started above it; valid range: 1..10000")
+	flag.BoolVar(&view.LogEvents, "logEvents", false, "logs the events of the games (games started, waypoints, Gophers spotted, died and won)")
+	flag.BoolVar(&view.PathFinding, "pathFinding", true, "default of the path finding setting in the UI web page: if enabled, any reachable block can be clicked")
 
 	flag.Parse()
 
@@ -49,20 +82,61 @@ func processFlags() error {
 		return fmt.Errorf("port %d is outside of valid range", port)
 	}
 
-	if model.Rows < 9 || model.Rows > 99 {
-		return fmt.Errorf("rows %d is outside of valid range", model.Rows)
+	if campaign {
+		if levelFile != "" {
+			return fmt.Errorf("level cannot be used in campaign mode")
+		}
+		var err error
+		if model.Campaign, err = model.ParseCampaign(progression); err != nil {
+			return fmt.Errorf("invalid progression: %v", err)
+		}
+		// Validate stages and use the size of the smallest level for the "global" size (to trim the view to it)
+		model.Rows, model.Cols = 99, 99
+		for i, st := range model.Campaign {
+			if err := validateSize(st.Rows, st.Cols); err != nil {
+				return fmt.Errorf("level %d of progression: %v", i+1, err)
+			}
+			if err := validateDifficulty(st.BulldogDensity, st.V); err != nil {
+				return fmt.Errorf("level %d of progression: %v", i+1, err)
+			}
+			if st.Rows < model.Rows {
+				model.Rows = st.Rows
+			}
+			if st.Cols < model.Cols {
+				model.Cols = st.Cols
+			}
+		}
+	} else if levelFile != "" {
+		var err error
+		if model.FixedLevel, err = model.LoadLevel(levelFile); err != nil {
+			return fmt.Errorf("invalid level file %s: %v", levelFile, err)
+		}
+		model.Rows, model.Cols = model.FixedLevel.Rows(), model.FixedLevel.Cols()
+	} else if err := validateSize(model.Rows, model.Cols); err != nil {
+		return err
+	}
+
+	if !campaign {
+		if err := validateDifficulty(model.BulldogDensity, model.V); err != nil {
+			return err
+		}
+	}
+
+	if model.Braid < 0 || model.Braid > 1 {
+		return fmt.Errorf("braid %f is outside of valid range", model.Braid)
 	}
 
-	if model.Cols < 9 || model.Cols > 99 {
-		return fmt.Errorf("cols %d is outside of valid range", model.Cols)
+	var err error
+	if model.BulldogBehaviors, err = model.ParseBehaviors(behaviors); err != nil {
+		return err
 	}
 
-	if model.Rows&0x01 == 0 {
-		return fmt.Errorf("rows %d must be odd", model.Rows)
+	if model.HuntTime < 10*time.Second || model.HuntTime > time.Hour {
+		return fmt.Errorf("huntTime %v is outside of valid range", model.HuntTime)
 	}
 
-	if model.Cols&0x01 == 0 {
-		return fmt.Errorf("cols %d must be odd", model.Cols)
+	if model.GeneratorByName(model.Algorithm) == nil {
+		return fmt.Errorf("algorithm %s is not a valid algorithm", model.Algorithm)
 	}
 
 	model.LabWidth = model.Cols * model.BlockSize
@@ -72,8 +146,29 @@ func processFlags() error {
 		return fmt.Errorf("loopDelay %d is outside of valid range", ctrl.LoopDelay)
 	}
 
-	if model.V < 20 || model.V > 200 {
-		return fmt.Errorf("v %f is outside of valid range", model.V)
+	if ctrl.TickRate < 10 || ctrl.TickRate > 200 {
+		return fmt.Errorf("tickRate %d is outside of valid range", ctrl.TickRate)
+	}
+
+	if ctrl.RecordDir != "" {
+		if fi, err := os.Stat(ctrl.RecordDir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("recordDir %s is not an existing directory", ctrl.RecordDir)
+		}
+	}
+
+	if replayFile != "" {
+		var err error
+		if view.InitialReplay, err = model.LoadReplay(replayFile); err != nil {
+			return fmt.Errorf("invalid replay file %s: %v", replayFile, err)
+		}
+	}
+
+	if view.SessionTimeout < time.Minute || view.SessionTimeout > 24*time.Hour {
+		return fmt.Errorf("sessionTimeout %v is outside of valid range", view.SessionTimeout)
+	}
+
+	if view.MaxGames < 1 || view.MaxGames > 10000 {
+		return fmt.Errorf("maxGames %d is outside of valid range", view.MaxGames)
 	}
 
 	if view.ViewWidth < 150 || view.ViewWidth > 2000 {
@@ -84,10 +179,6 @@ func processFlags() error {
 		return fmt.Errorf("viewHeight %d is outside of valid range", view.ViewHeight)
 	}
 
-	if model.BulldogDensity < 0 || model.BulldogDensity > 50 {
-		return fmt.Errorf("bulldogs %f is outside of valid range", model.BulldogDensity)
-	}
-
 	if view.ViewWidth > model.LabWidth {
 		fmt.Printf("Warning: viewWidth is trimmed to cols * %d = %d\n", model.BlockSize, model.LabWidth)
 		view.ViewWidth = model.LabWidth
@@ -101,20 +192,65 @@ func processFlags() error {
 	return nil
 }
 
+// validateSize validates the size of the Labyrinth.
+// Returns nil if everything is ok, else an error.
+func validateSize(rows, cols int) error {
+	if rows < 9 || rows > 99 {
+		return fmt.Errorf("rows %d is outside of valid range", rows)
+	}
+
+	if cols < 9 || cols > 99 {
+		return fmt.Errorf("cols %d is outside of valid range", cols)
+	}
+
+	if rows&0x01 == 0 {
+		return fmt.Errorf("rows %d must be odd", rows)
+	}
+
+	if cols&0x01 == 0 {
+		return fmt.Errorf("cols %d must be odd", cols)
+	}
+
+	return nil
+}
+
+// validateDifficulty validates the parameters defining the difficulty of the game.
+// Returns nil if everything is ok, else an error.
+func validateDifficulty(bulldogDensity, v float64) error {
+	if bulldogDensity < 0 || bulldogDensity > 50 {
+		return fmt.Errorf("bulldogs %f is outside of valid range", bulldogDensity)
+	}
+
+	if v < 20 || v > 200 {
+		return fmt.Errorf("v %f is outside of valid range", v)
+	}
+
+	return nil
+}
+
 // main is the entry point of GoLab.
-// Processes the command line flags, initializes the game engine
-// and starts the UI webserver.
+// Processes the command line flags and starts the UI webserver.
+// Game engines are started for each browser session.
+// If the first argument is the tournament subcommand, runs a tournament of bots instead.
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
+	if len(os.Args) > 1 && os.Args[1] == tournamentCmd {
+		if err := runTournament(os.Args[2:]); err != nil {
+			if err != flag.ErrHelp {
+				fmt.Println(err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := processFlags(); err != nil {
 		fmt.Println(err)
 		flag.Usage()
 		return
 	}
 
-	ctrl.StartEngine()
-
 	fmt.Printf("Starting GoLab webserver on port %d...\n", port)
 	url := fmt.Sprintf("http://localhost:%d/", port)
 	if autoOpen {
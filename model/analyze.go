@@ -0,0 +1,156 @@
+package model
+
+import (
+	"image"
+)
+
+// Stats holds metrics of a level which help to rate its difficulty.
+// Positions are in block coordinates (X is the column, Y is the row).
+type Stats struct {
+	// Path is the shortest path from the start to the exit, both inclusive; nil if the exit is unreachable
+	Path []image.Point `json:"path"`
+
+	// PathLength is the length of the shortest path in blocks (number of steps); -1 if the exit is unreachable
+	PathLength int `json:"pathLength"`
+
+	// DeadEnds is the number of dead ends (free blocks having exactly 1 free neighbour)
+	DeadEnds int `json:"deadEnds"`
+
+	// Junctions is the number of junctions (free blocks having at least 3 free neighbours)
+	Junctions int `json:"junctions"`
+
+	// BranchingFactor is the average number of ways to go on at junctions (not counting the way we came from);
+	// 0 if there are no junctions
+	BranchingFactor float64 `json:"branchingFactor"`
+
+	// LongestCorridor is the length of the longest corridor in blocks.
+	// A corridor is a sequence of connected free blocks which are not junctions,
+	// so there is no way to get out of it other than its ends.
+	LongestCorridor int `json:"longestCorridor"`
+}
+
+// freeNeighbours returns the number of free neighbour blocks of the block at p.
+func freeNeighbours(lab [][]Block, p image.Point) (n int) {
+	for _, d := range dirDeltas {
+		if isFree(lab, p.Add(d)) {
+			n++
+		}
+	}
+	return
+}
+
+// isFree tells if the block at p is inside the labyrinth and is free.
+func isFree(lab [][]Block, p image.Point) bool {
+	return p.Y >= 0 && p.Y < len(lab) && p.X >= 0 && p.X < len(lab[p.Y]) && lab[p.Y][p.X] == BlockEmpty
+}
+
+// Analyze computes the Stats of the level.
+func (l *Level) Analyze() *Stats {
+	s := &Stats{Path: ShortestPath(l.Lab, l.Start, l.Exit)}
+	s.PathLength = len(s.Path) - 1
+
+	// degree of the free blocks (number of free neighbours)
+	degree := map[image.Point]int{}
+	branches := 0
+	for ri, row := range l.Lab {
+		for ci, block := range row {
+			if block != BlockEmpty {
+				continue
+			}
+			p := image.Pt(ci, ri)
+			d := freeNeighbours(l.Lab, p)
+			degree[p] = d
+			switch {
+			case d == 1:
+				s.DeadEnds++
+			case d >= 3:
+				s.Junctions++
+				branches += d - 1
+			}
+		}
+	}
+	if s.Junctions > 0 {
+		s.BranchingFactor = float64(branches) / float64(s.Junctions)
+	}
+
+	// Corridors are the connected components of non-junction blocks, use flood fill to measure them:
+	visited := map[image.Point]bool{}
+	for p, d := range degree {
+		if d >= 3 || visited[p] {
+			continue
+		}
+		length := 0
+		visited[p] = true
+		for stack := []image.Point{p}; len(stack) > 0; {
+			q := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			length++
+			for _, dd := range dirDeltas {
+				n := q.Add(dd)
+				if nd, ok := degree[n]; ok && nd < 3 && !visited[n] {
+					visited[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+		if length > s.LongestCorridor {
+			s.LongestCorridor = length
+		}
+	}
+
+	return s
+}
+
+// ShortestPath returns the shortest path between the blocks from and to (both inclusive),
+// moving only horizontally and vertically through free blocks.
+// Returns nil if to is not reachable from from.
+// This is a breadth-first search.
+func ShortestPath(lab [][]Block, from, to image.Point) []image.Point {
+	if !isFree(lab, from) || !isFree(lab, to) {
+		return nil
+	}
+
+	// prev holds the previous block on the shortest path for each visited block
+	prev := map[image.Point]image.Point{from: from}
+	for queue := []image.Point{from}; len(queue) > 0; {
+		p := queue[0]
+		queue = queue[1:]
+
+		if p == to {
+			// Reached target, reconstruct the path backward
+			var path []image.Point
+			for ; p != from; p = prev[p] {
+				path = append(path, p)
+			}
+			path = append(path, from)
+			// Reverse it
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+
+		for _, d := range dirDeltas {
+			n := p.Add(d)
+			if _, ok := prev[n]; !ok && isFree(lab, n) {
+				prev[n] = p
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PathCorners returns the corners of the specified path where the direction changes, and its end point.
+// The start point is not included.
+// Moving along the returned points one after the other in straight lines follows the path.
+func PathCorners(path []image.Point) []image.Point {
+	var corners []image.Point
+	for i := 1; i < len(path); i++ {
+		if i == len(path)-1 || path[i].Sub(path[i-1]) != path[i+1].Sub(path[i]) {
+			corners = append(corners, path[i])
+		}
+	}
+	return corners
+}
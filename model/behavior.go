@@ -0,0 +1,260 @@
+package model
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"time"
+)
+
+// Behavior is the interface of the strategies of the Bulldogs: a Behavior decides where a Bulldog goes next.
+//
+// Each Bulldog has its own Behavior instance, so Behaviors may have state.
+// Everything random must use the Rand of the Game, so games can be reproduced from their seed.
+type Behavior interface {
+	// Name returns the name of the behavior, this is used to select it (e.g. by the -behaviors flag).
+	Name() string
+
+	// Next returns the next target block of the Bulldog (in block coordinates) which reached its current target.
+	// The target must be in the same row or column as the current block, with a free straight passage to it.
+	Next(g *Game, bd *Bulldog) image.Point
+}
+
+// behaviorFactories is the list of the built-in Behaviors, in the form of functions creating new instances.
+var behaviorFactories = []func() Behavior{
+	func() Behavior { return wanderBehavior{} },
+	func() Behavior { return &chaseBehavior{} },
+	func() Behavior { return pursuitBehavior{} },
+	func() Behavior { return &patrolBehavior{} },
+	func() Behavior { return scatterBehavior{} },
+}
+
+// BulldogBehaviors are the default names of the Behaviors of the Bulldogs, assigned to them in turns.
+var BulldogBehaviors = []string{wanderBehavior{}.Name()}
+
+// NewBehavior returns a new instance of the built-in Behavior having the specified name,
+// nil if there is no such Behavior.
+func NewBehavior(name string) Behavior {
+	for _, f := range behaviorFactories {
+		if b := f(); b.Name() == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// BehaviorNames returns the names of the built-in Behaviors.
+func BehaviorNames() []string {
+	names := make([]string, len(behaviorFactories))
+	for i, f := range behaviorFactories {
+		names[i] = f().Name()
+	}
+	return names
+}
+
+// ParseBehaviors parses a comma separated list of Behavior names.
+func ParseBehaviors(s string) ([]string, error) {
+	names := strings.Split(s, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if NewBehavior(names[i]) == nil {
+			return nil, fmt.Errorf("unknown behavior: %q", name)
+		}
+	}
+	return names, nil
+}
+
+// Bulldog is a moving object controlled by a Behavior.
+type Bulldog struct {
+	*MovingObj
+
+	// Behavior decides where the Bulldog goes
+	Behavior Behavior
+
+	// Spawn is the starting position of the Bulldog in block coordinates
+	Spawn image.Point
+}
+
+// Block returns the block of the Bulldog's target position in block coordinates.
+// When Behavior.Next() is called, this is the block where the Bulldog is.
+func (bd *Bulldog) Block() image.Point {
+	return image.Pt(bd.TargetPos.X/BlockSize, bd.TargetPos.Y/BlockSize)
+}
+
+// hunt returns the next block on the shortest path from the specified block to the nearest racing Gopher.
+// If no Gopher is racing, a random target is returned.
+func (g *Game) hunt(block image.Point) image.Point {
+	if i := g.nearestPlayer(block); i >= 0 {
+		return g.pursue(block, g.Players[i].Block())
+	}
+	return g.wander(block)
+}
+
+// wanderBehavior wanders randomly: it goes in a random free direction at every block.
+type wanderBehavior struct{}
+
+func (wanderBehavior) Name() string { return "random" }
+
+func (wanderBehavior) Next(g *Game, bd *Bulldog) image.Point {
+	return g.wander(bd.Block())
+}
+
+// wander returns a random target from the specified block:
+// a random free direction is chosen, and if possible, 2 blocks are stepped in that direction.
+func (g *Game) wander(block image.Point) image.Point {
+	// Shuffle the directions (always start from the same order, the outcome must only depend on Rand).
+	// First one in which direction there is a free path wins (such path surely exists in generated labyrinths).
+	directions := [...]Dir{DirRight, DirLeft, DirUp, DirDown}
+	for i := len(directions) - 1; i > 0; i-- { // last is already random, no use switching with itself
+		r := g.Rand.Intn(i + 1)
+		directions[i], directions[r] = directions[r], directions[i]
+	}
+
+	for _, dir := range directions {
+		d := dir.Delta()
+		if isFree(g.Lab, block.Add(d)) {
+			// Direction is good, check if we can even step 2 blocks in this way:
+			if isFree(g.Lab, block.Add(d.Mul(2))) {
+				return block.Add(d.Mul(2))
+			}
+			return block.Add(d)
+		}
+	}
+	return block
+}
+
+// stepTowards returns the neighbour block of from in the direction of to,
+// which must be in the same row or column as from.
+func stepTowards(from, to image.Point) image.Point {
+	switch {
+	case to.X > from.X:
+		from.X++
+	case to.X < from.X:
+		from.X--
+	case to.Y > from.Y:
+		from.Y++
+	case to.Y < from.Y:
+		from.Y--
+	}
+	return from
+}
+
+// InSight tells if the block to is visible from the block from:
+// they are in the same row or column, and there is a free straight passage between them.
+func InSight(lab [][]Block, from, to image.Point) bool {
+	if from.X != to.X && from.Y != to.Y {
+		return false
+	}
+	for p := from; p != to; p = stepTowards(p, to) {
+		if !isFree(lab, p) {
+			return false
+		}
+	}
+	return isFree(lab, to)
+}
+
+// chaseBehavior chases a Gopher if it's in line of sight: it runs to the block where a Gopher was last seen,
+// and wanders randomly if no Gopher is seen.
+type chaseBehavior struct {
+	// lastSeen is the block where a Gopher was last seen, valid if hunting is true
+	lastSeen image.Point
+	hunting  bool
+}
+
+func (*chaseBehavior) Name() string { return "chase" }
+
+func (c *chaseBehavior) Next(g *Game, bd *Bulldog) image.Point {
+	block := bd.Block()
+	for _, p := range g.Players {
+		if gb := p.Block(); p.Racing() && InSight(g.Lab, block, gb) {
+			c.lastSeen, c.hunting = gb, true
+			break
+		}
+	}
+
+	if c.hunting && block != c.lastSeen {
+		// Only step one block so we can look around at every block
+		return stepTowards(block, c.lastSeen)
+	}
+	c.hunting = false
+	return g.wander(block)
+}
+
+// pursuitBehavior always follows the shortest path to the nearest Gopher.
+type pursuitBehavior struct{}
+
+func (pursuitBehavior) Name() string { return "pursuit" }
+
+func (pursuitBehavior) Next(g *Game, bd *Bulldog) image.Point {
+	return g.hunt(bd.Block())
+}
+
+// pursue returns the next block on the shortest path from the block from to the block to.
+// If to is not reachable (or it's from itself), a random target is returned.
+func (g *Game) pursue(from, to image.Point) image.Point {
+	if path := ShortestPath(g.Lab, from, to); len(path) > 1 {
+		return path[1]
+	}
+	return g.wander(from)
+}
+
+// patrolBehavior patrols a fixed route: it walks back and forth between its spawn position
+// and a random distant block along the shortest path.
+type patrolBehavior struct {
+	// route is the patrol route, nil if not yet chosen
+	route []image.Point
+	// idx is the index of the current block in route, step is the direction of walking on route (1 or -1)
+	idx, step int
+}
+
+func (*patrolBehavior) Name() string { return "patrol" }
+
+// minPatrolLength is the desired minimum length of patrol routes in blocks.
+const minPatrolLength = 10
+
+func (p *patrolBehavior) Next(g *Game, bd *Bulldog) image.Point {
+	if p.route == nil {
+		// Choose the other end of the route: the farthest of a few random free blocks
+		from := bd.Block()
+		for try := 0; try < 10 && len(p.route) <= minPatrolLength; try++ {
+			to := image.Pt(g.Rand.Intn(g.Cols), g.Rand.Intn(g.Rows))
+			if path := ShortestPath(g.Lab, from, to); len(path) > len(p.route) {
+				p.route = path
+			}
+		}
+		if len(p.route) < 2 {
+			p.route = []image.Point{from, g.wander(from)}
+		}
+		p.step = 1
+	}
+
+	if next := p.idx + p.step; next < 0 || next >= len(p.route) {
+		// Reached an end of the route, turn back
+		p.step = -p.step
+	}
+	p.idx += p.step
+	return p.route[p.idx]
+}
+
+// Durations of the phases of the scatter behavior.
+const (
+	scatterDuration = 7 * time.Second
+	chaseDuration   = 20 * time.Second
+)
+
+// scatterBehavior alternates between pursuing the nearest Gopher and retreating to its spawn position.
+// The first phase is the retreat (scatter) which lasts scatterDuration, followed by the pursuit for chaseDuration.
+type scatterBehavior struct{}
+
+func (scatterBehavior) Name() string { return "scatter" }
+
+func (scatterBehavior) Next(g *Game, bd *Bulldog) image.Point {
+	block := bd.Block()
+	if g.LevelTime%(scatterDuration+chaseDuration) >= scatterDuration {
+		return g.hunt(block)
+	}
+	if block == bd.Spawn {
+		return g.wander(block)
+	}
+	return g.pursue(block, bd.Spawn)
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"math/rand"
+)
+
+// Braid is the fraction of dead ends to remove from generated labyrinths, in the range of 0..1.
+// 0 leaves the generated labyrinth perfect (there is exactly one route between any 2 points),
+// 1 removes all dead ends. Removing dead ends creates loops, which gives alternative routes around Bulldogs.
+var Braid float64
+
+// braid removes the specified fraction of the dead ends of the labyrinth by knocking out one of their walls.
+// The outer frame of the labyrinth is left intact.
+func braid(lab [][]Block, fraction float64, r *rand.Rand) {
+	// openSides returns the number of open sides of a cell
+	openSides := func(c cell) (n int) {
+		for _, d := range cellDeltas {
+			if lab[c.row+d.row/2][c.col+d.col/2] == BlockEmpty {
+				n++
+			}
+		}
+		return
+	}
+
+	var deadEnds []cell
+	for _, c := range cells(lab) {
+		if openSides(c) == 1 {
+			deadEnds = append(deadEnds, c)
+		}
+	}
+
+	count := int(float64(len(deadEnds))*fraction + 0.5)
+	for _, i := range r.Perm(len(deadEnds))[:count] {
+		c := deadEnds[i]
+		// A previous removal might have already removed this dead end
+		if openSides(c) != 1 {
+			continue
+		}
+
+		// Candidates are the neighbours separated by a wall.
+		// Prefer neighbours which are dead ends too: this way 2 dead ends are removed at once.
+		var walled, deadEndNs []cell
+		for _, n := range neighbours(lab, c) {
+			if lab[(c.row+n.row)/2][(c.col+n.col)/2] == BlockWall {
+				walled = append(walled, n)
+				if openSides(n) == 1 {
+					deadEndNs = append(deadEndNs, n)
+				}
+			}
+		}
+		if len(deadEndNs) > 0 {
+			walled = deadEndNs
+		}
+		if len(walled) == 0 {
+			continue
+		}
+
+		carve(lab, c, walled[r.Intn(len(walled))])
+	}
+}
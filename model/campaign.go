@@ -0,0 +1,100 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stage describes the parameters of a level of the campaign.
+type Stage struct {
+	// Rows and Cols are the size of the Labyrinth
+	Rows, Cols int
+
+	// BulldogDensity is the Bulldog density of the level
+	BulldogDensity float64
+
+	// V is the moving speed of Gopher and the Bulldogs in pixel/sec
+	V float64
+}
+
+// String returns the Stage in the format parsed by ParseCampaign.
+func (s Stage) String() string {
+	return fmt.Sprintf("%dx%d:%g:%g", s.Rows, s.Cols, s.BulldogDensity, s.V)
+}
+
+// DefaultProgression is the default progression table of the campaign.
+const DefaultProgression = "15x15:4:60,21x21:6:70,25x25:8:80,31x31:10:90,41x41:12:100,51x51:15:110"
+
+// Campaign is the default progression table of the campaign: the parameters of its levels in order.
+// If empty, campaign mode is disabled and every game is a single level.
+var Campaign []Stage
+
+// The state of the campaign is stored in the Game:
+// CampaignLevel is the index of the current level in the campaign,
+// LevelTime is the time spent in the current level,
+// CampaignTime is the total time spent in the completed levels of the campaign.
+
+// ParseCampaign parses a progression table.
+// The table is a comma separated list of stages, each in the format of "ROWSxCOLS:BULLDOGS:V",
+// for example "15x15:4:60,21x21:6:70".
+func ParseCampaign(s string) ([]Stage, error) {
+	var stages []Stage
+	for i, part := range strings.Split(s, ",") {
+		var st Stage
+		if _, err := fmt.Sscanf(strings.TrimSpace(part), "%dx%d:%g:%g", &st.Rows, &st.Cols, &st.BulldogDensity, &st.V); err != nil {
+			return nil, fmt.Errorf("invalid stage #%d (%q): %v", i+1, part, err)
+		}
+		stages = append(stages, st)
+	}
+	return stages, nil
+}
+
+// HasNextLevel tells if the campaign has more levels after the current.
+func (g *Game) HasNextLevel() bool {
+	return g.CampaignLevel+1 < len(g.Campaign)
+}
+
+// initStage applies the parameters of the specified campaign level.
+// If this is the first level, the campaign is restarted.
+func (g *Game) initStage(level int) {
+	st := g.Campaign[level]
+	g.Rows, g.Cols = st.Rows, st.Cols
+	g.LabWidth, g.LabHeight = g.Cols*BlockSize, g.Rows*BlockSize
+	g.BulldogDensity = st.BulldogDensity
+	g.V = st.V
+
+	g.CampaignLevel = level
+	if level == 0 {
+		g.CampaignTime = 0
+	}
+}
+
+// LevelCompleted is to be called when the current level of the campaign is completed.
+// Returns the lines of text to be displayed on the level complete screen.
+func (g *Game) LevelCompleted() []string {
+	g.CampaignTime += g.LevelTime
+	levelTime := g.LevelTime
+	g.LevelTime = 0
+
+	lines := []string{
+		fmt.Sprintf("Level %d/%d complete!", g.CampaignLevel+1, len(g.Campaign)),
+		"",
+		"Time:  " + FormatDuration(levelTime),
+		"Total: " + FormatDuration(g.CampaignTime),
+		"",
+	}
+	if g.HasNextLevel() {
+		lines = append(lines, "Click to continue")
+	} else {
+		lines[0] = "Campaign complete!"
+		lines = append(lines, "Start a new game to play again")
+	}
+	return lines
+}
+
+// FormatDuration formats a duration in the form of "M:SS.T".
+func FormatDuration(d time.Duration) string {
+	d = d / (100 * time.Millisecond)
+	return fmt.Sprintf("%d:%02d.%d", d/600, d/10%60, d%10)
+}
@@ -1,27 +1,35 @@
 package model
 
+import (
+	"image"
+)
+
 const (
 	// BlockSize is the size of the labyrinth unit in pixels.
 	BlockSize = 40
 )
 
 var (
-	// Rows is the number of rows in the Labyrinth
+	// Rows is the default number of rows in the Labyrinth of new games
 	Rows int
-	// Cols is the number of columns in the Labyrinth
+	// Cols is the default number of columns in the Labyrinth of new games
 	Cols int
 
-	// LabWidth is the width of the labyrinth's image in pixels.
+	// LabWidth is the default width of the labyrinth's image in pixels.
 	LabWidth int
 
-	// LabHeight is the height of the labyrinth's image in pixels.
+	// LabHeight is the default height of the labyrinth's image in pixels.
 	LabHeight int
 )
 
-// V is the moving speed of Gopher and the Buddlogs in pixel/sec.
+// V is the default moving speed of Gopher and the Buddlogs in pixel/sec.
 var V float64
 
-// "Bulldog density", it tells how many Bulldogs to generate for average of 1,000 blocks.
+// Seed is the default seed of the random number generator of the games.
+// 0 means a random seed is chosen for each new game.
+var Seed int64
+
+// Default "Bulldog density", it tells how many Bulldogs to generate for average of 1,000 blocks.
 // For example if this is 10.0 and rows*cols = 21*21 = 441, 10.0*441/1000 = 4.41 => 4 Bulldogs will be generated.
 var BulldogDensity float64
 
@@ -49,6 +57,38 @@ const (
 	DirLength
 )
 
+// dirDeltas are the unit vectors of the directions in block coordinates, indexed by Dir.
+var dirDeltas = [...]image.Point{DirRight: {1, 0}, DirLeft: {-1, 0}, DirUp: {0, -1}, DirDown: {0, 1}}
+
+// Delta returns the unit vector of the direction in block (and pixel) coordinates.
+func (d Dir) Delta() image.Point {
+	return dirDeltas[d]
+}
+
+// Opposite returns the opposite direction.
+func (d Dir) Opposite() Dir {
+	switch d {
+	case DirRight:
+		return DirLeft
+	case DirLeft:
+		return DirRight
+	case DirUp:
+		return DirDown
+	}
+	return DirUp
+}
+
+// DirByName returns the direction having the specified name (as returned by Dir.String()).
+// The second return value tells if there is such direction.
+func DirByName(name string) (Dir, bool) {
+	for d := Dir(0); d < DirLength; d++ {
+		if d.String() == name {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
 func (d Dir) String() string {
 	switch d {
 	case DirRight:
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"fmt"
+	"image"
+	"sync"
+)
+
+// EventType is the type of a game event.
+type EventType int
+
+// Types of game events
+const (
+	// A new game started (also a new level of a campaign)
+	EventGameStarted EventType = iota
+	// A waypoint (mouse click) was accepted: targets were queued
+	EventWaypointAccepted
+	// A waypoint (mouse click) was rejected: the clicked block cannot be reached
+	// (or the path to it does not fit into the target buffer of the Gopher)
+	EventWaypointRejected
+	// A Gopher reached a queued target
+	EventTargetReached
+	// A Bulldog spotted a Gopher: the Gopher got into its line of sight
+	EventBulldogSpotted
+	// A Bulldog caught a Gopher
+	EventGopherDied
+	// A Gopher reached the exit
+	EventGopherWon
+
+	// EventTypeLength is the number of event types
+	EventTypeLength
+)
+
+// eventTypeNames are the names of the event types, indexed by EventType.
+var eventTypeNames = [...]string{
+	EventGameStarted:      "gameStarted",
+	EventWaypointAccepted: "waypointAccepted",
+	EventWaypointRejected: "waypointRejected",
+	EventTargetReached:    "targetReached",
+	EventBulldogSpotted:   "bulldogSpotted",
+	EventGopherDied:       "gopherDied",
+	EventGopherWon:        "gopherWon",
+}
+
+func (t EventType) String() string {
+	if t >= 0 && t < EventTypeLength {
+		return eventTypeNames[t]
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler, event types are encoded by their names (e.g. in JSON).
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, the inverse of MarshalText.
+func (t *EventType) UnmarshalText(text []byte) error {
+	for i, name := range eventTypeNames {
+		if name == string(text) {
+			*t = EventType(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type: %q", text)
+}
+
+// Event is an event of a game, published by the engine of the game on its EventBus.
+type Event struct {
+	// Type is the type of the event
+	Type EventType `json:"type"`
+
+	// Tick is the simulation tick when the event happened
+	Tick int64 `json:"tick"`
+
+	// Replay tells if the event happened in a replay being played back
+	Replay bool `json:"replay,omitempty"`
+
+	// Game holds the parameters of the started game (EventGameStarted only)
+	Game *NewGame `json:"game,omitempty"`
+
+	// Player is the index of the player concerned (not used in EventGameStarted and in events of the hunter)
+	Player int `json:"player"`
+
+	// Hunter tells if the waypoint event is of the hunter (Player is not used then)
+	Hunter bool `json:"hunter,omitempty"`
+
+	// Bulldog is the index of the Bulldog which spotted or caught the Gopher
+	// (EventBulldogSpotted and EventGopherDied only)
+	Bulldog int `json:"bulldog"`
+
+	// Block is the block where the event happened: the clicked block of waypoint events,
+	// the reached target, or the block of the Gopher in the other events (in block coordinates)
+	Block image.Point `json:"block"`
+}
+
+// EventBus distributes the events of a game to its subscribers.
+//
+// Events are delivered on buffered channels. Publishing never blocks:
+// if the channel of a subscriber is full, the event is dropped for that subscriber.
+// EventBus is safe for concurrent use.
+type EventBus struct {
+	// mutex protects the fields of the EventBus
+	mutex sync.Mutex
+
+	// subs are the current subscriptions
+	subs []*Subscription
+
+	// closed tells if the EventBus has been closed
+	closed bool
+}
+
+// Subscription is a subscription to the events of an EventBus.
+type Subscription struct {
+	// C is the channel on which the events are delivered.
+	// It is closed when the subscription or the EventBus is closed.
+	C <-chan Event
+
+	// c is the same channel as C, for sending
+	c chan Event
+
+	// bus is the EventBus of the subscription
+	bus *EventBus
+}
+
+// Subscribe subscribes to the events of the bus.
+// size is the buffer size of the channel of the subscription, events are dropped if it is full.
+// If the bus is already closed, the channel of the returned subscription is closed.
+func (b *EventBus) Subscribe(size int) *Subscription {
+	c := make(chan Event, size)
+	s := &Subscription{C: c, c: c, bus: b}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		close(c)
+	} else {
+		b.subs = append(b.subs, s)
+	}
+	return s
+}
+
+// Close cancels the subscription and closes its channel. Close may be called multiple times.
+func (s *Subscription) Close() {
+	b := s.bus
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for i, s2 := range b.subs {
+		if s2 == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			close(s.c)
+			break
+		}
+	}
+}
+
+// Publish delivers the specified event to all subscribers.
+func (b *EventBus) Publish(ev Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, s := range b.subs {
+		// Use non-blocking send
+		select {
+		case s.c <- ev:
+		default:
+		}
+	}
+}
+
+// Close closes the bus and the channels of all subscriptions.
+func (b *EventBus) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, s := range b.subs {
+		close(s.c)
+	}
+	b.subs, b.closed = nil, true
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+// Size of the glyphs of the built-in font, in font pixels.
+const (
+	GlyphWidth  = 5
+	GlyphHeight = 7
+)
+
+// glyphs is a tiny built-in bitmap font so we can render text (e.g. status messages) without external fonts.
+// Each glyph has GlyphHeight rows, each row has GlyphWidth columns, '#' marks a set pixel.
+// Only upper case letters, digits and a few punctuation characters are defined;
+// lower case letters are rendered as upper case, undefined characters as space.
+var glyphs = map[rune][GlyphHeight]string{
+	'A': {" ### ", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'B': {"#### ", "#   #", "#   #", "#### ", "#   #", "#   #", "#### "},
+	'C': {" ### ", "#   #", "#    ", "#    ", "#    ", "#   #", " ### "},
+	'D': {"#### ", "#   #", "#   #", "#   #", "#   #", "#   #", "#### "},
+	'E': {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#####"},
+	'F': {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#    "},
+	'G': {" ### ", "#   #", "#    ", "# ###", "#   #", "#   #", " ####"},
+	'H': {"#   #", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'I': {" ### ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'J': {"  ###", "   # ", "   # ", "   # ", "   # ", "#  # ", " ##  "},
+	'K': {"#   #", "#  # ", "# #  ", "##   ", "# #  ", "#  # ", "#   #"},
+	'L': {"#    ", "#    ", "#    ", "#    ", "#    ", "#    ", "#####"},
+	'M': {"#   #", "## ##", "# # #", "# # #", "#   #", "#   #", "#   #"},
+	'N': {"#   #", "#   #", "##  #", "# # #", "#  ##", "#   #", "#   #"},
+	'O': {" ### ", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'P': {"#### ", "#   #", "#   #", "#### ", "#    ", "#    ", "#    "},
+	'Q': {" ### ", "#   #", "#   #", "#   #", "# # #", "#  # ", " ## #"},
+	'R': {"#### ", "#   #", "#   #", "#### ", "# #  ", "#  # ", "#   #"},
+	'S': {" ####", "#    ", "#    ", " ### ", "    #", "    #", "#### "},
+	'T': {"#####", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U': {"#   #", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'V': {"#   #", "#   #", "#   #", "#   #", "#   #", " # # ", "  #  "},
+	'W': {"#   #", "#   #", "#   #", "# # #", "# # #", "# # #", " # # "},
+	'X': {"#   #", "#   #", " # # ", "  #  ", " # # ", "#   #", "#   #"},
+	'Y': {"#   #", "#   #", " # # ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'Z': {"#####", "    #", "   # ", "  #  ", " #   ", "#    ", "#####"},
+	'0': {" ### ", "#   #", "#  ##", "# # #", "##  #", "#   #", " ### "},
+	'1': {"  #  ", " ##  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'2': {" ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####"},
+	'3': {"#####", "   # ", "  #  ", "   # ", "    #", "#   #", " ### "},
+	'4': {"   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # "},
+	'5': {"#####", "#    ", "#### ", "    #", "    #", "#   #", " ### "},
+	'6': {"  ## ", " #   ", "#    ", "#### ", "#   #", "#   #", " ### "},
+	'7': {"#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   "},
+	'8': {" ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### "},
+	'9': {" ### ", "#   #", "#   #", " ####", "    #", "   # ", " ##  "},
+	':': {"     ", "  #  ", "  #  ", "     ", "  #  ", "  #  ", "     "},
+	'.': {"     ", "     ", "     ", "     ", "     ", " ##  ", " ##  "},
+	'!': {"  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "     ", "  #  "},
+	'/': {"     ", "    #", "   # ", "  #  ", " #   ", "#    ", "     "},
+	'-': {"     ", "     ", "     ", "#####", "     ", "     ", "     "},
+}
+
+// TextSize returns the size of the specified text rendered with DrawText in pixels.
+// scale is the size of a font pixel in (image) pixels.
+func TextSize(text string, scale int) image.Point {
+	n := len([]rune(text))
+	if n == 0 {
+		return image.Point{}
+	}
+	// There is 1 font pixel space between characters
+	return image.Pt((n*(GlyphWidth+1)-1)*scale, GlyphHeight*scale)
+}
+
+// DrawText draws the specified text onto dst with the built-in font.
+// pt is the top-left point of the text, scale is the size of a font pixel in (image) pixels.
+func DrawText(dst draw.Image, text string, pt image.Point, scale int, c color.Color) {
+	src := image.NewUniform(c)
+	for i, ch := range []rune(strings.ToUpper(text)) {
+		g, ok := glyphs[ch]
+		if !ok {
+			continue
+		}
+		x0 := pt.X + i*(GlyphWidth+1)*scale
+		for gy, row := range g {
+			for gx, px := range row {
+				if px != '#' {
+					continue
+				}
+				r := image.Rect(0, 0, scale, scale).Add(image.Pt(x0+gx*scale, pt.Y+gy*scale))
+				draw.Draw(dst, r, src, image.Point{}, draw.Over)
+			}
+		}
+	}
+}
+
+// DrawTextCentered draws the specified lines of text onto dst with the built-in font
+// on a semi-transparent panel, centered at the specified point.
+func DrawTextCentered(dst draw.Image, lines []string, center image.Point, scale int, c color.Color) {
+	// Size of the text block, with a half line space between the lines
+	lineHeight := GlyphHeight * scale * 3 / 2
+	var size image.Point
+	for _, line := range lines {
+		if w := TextSize(line, scale).X; w > size.X {
+			size.X = w
+		}
+	}
+	size.Y = len(lines)*lineHeight - (lineHeight - GlyphHeight*scale)
+
+	// Panel with some padding
+	pad := GlyphHeight * scale
+	r := image.Rectangle{Max: size}.Add(center.Sub(size.Div(2)))
+	draw.Draw(dst, r.Inset(-pad), panelImg, image.Point{}, draw.Over)
+
+	for i, line := range lines {
+		w := TextSize(line, scale).X
+		DrawText(dst, line, image.Pt(center.X-w/2, r.Min.Y+i*lineHeight), scale, c)
+	}
+}
+
+// FitScale returns the biggest scale (not bigger than max) at which the lines of text fit
+// into the specified width when drawn with DrawTextCentered. The minimum returned scale is 1.
+func FitScale(lines []string, width, max int) int {
+	scale := max
+	for ; scale > 1; scale-- {
+		fits := true
+		for _, line := range lines {
+			// Count the padding of the panel too
+			if TextSize(line, scale).X+2*GlyphHeight*scale > width {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			break
+		}
+	}
+	return scale
+}
+
+// panelImg is the image of the panel behind texts drawn with DrawTextCentered.
+var panelImg = image.NewUniform(color.RGBA{A: 0xc0})
@@ -0,0 +1,428 @@
+package model
+
+import (
+	"math/rand"
+)
+
+// Generator is the interface of the labyrinth generator algorithms.
+//
+// All generators work on the same grid: the labyrinth has an odd number of rows and columns,
+// blocks having odd row and column indices are the passages ("cells"),
+// walls may only be placed at blocks having an even row or column index, and the outer frame is always wall.
+// This is what the ctrl and view packages rely on.
+type Generator interface {
+	// Name returns the name of the algorithm, this is used to select it (e.g. by the -algorithm flag).
+	Name() string
+
+	// Generate generates a random labyrinth into lab using the specified random number generator.
+	// lab is a matrix of odd rows and columns, full of empty blocks.
+	// The result must only depend on the state of r, so games can be reproduced from their seed.
+	Generate(lab [][]Block, r *rand.Rand)
+}
+
+// Generators is the list of the built-in labyrinth generator algorithms.
+var Generators = []Generator{
+	divisionGen{},
+	backtrackerGen{},
+	primGen{},
+	kruskalGen{},
+	wilsonGen{},
+	ellerGen{},
+}
+
+// Algorithm is the name of the default labyrinth generator algorithm.
+var Algorithm = divisionGen{}.Name()
+
+// GeneratorByName returns the built-in Generator having the specified name, nil if there is no such Generator.
+func GeneratorByName(name string) Generator {
+	for _, g := range Generators {
+		if g.Name() == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// GeneratorNames returns the names of the built-in Generators.
+func GeneratorNames() []string {
+	names := make([]string, len(Generators))
+	for i, g := range Generators {
+		names[i] = g.Name()
+	}
+	return names
+}
+
+// cell is a passage block of the labyrinth (having odd row and column).
+type cell struct {
+	row, col int
+}
+
+// cellDeltas are the row and column deltas of the neighbour cells in each direction.
+var cellDeltas = [...]cell{{0, 2}, {0, -2}, {-2, 0}, {2, 0}}
+
+// fillWalls fills the whole labyrinth with walls.
+// This is the starting point of the algorithms which carve passages.
+func fillWalls(lab [][]Block) {
+	for _, row := range lab {
+		for ci := range row {
+			row[ci] = BlockWall
+		}
+	}
+}
+
+// cells returns all the cells of the labyrinth.
+func cells(lab [][]Block) []cell {
+	var cs []cell
+	for ri := 1; ri < len(lab)-1; ri += 2 {
+		for ci := 1; ci < len(lab[ri])-1; ci += 2 {
+			cs = append(cs, cell{ri, ci})
+		}
+	}
+	return cs
+}
+
+// neighbours returns the neighbour cells of c which are inside the frame of the labyrinth.
+func neighbours(lab [][]Block, c cell) []cell {
+	ns := make([]cell, 0, len(cellDeltas))
+	for _, d := range cellDeltas {
+		n := cell{c.row + d.row, c.col + d.col}
+		if n.row > 0 && n.row < len(lab)-1 && n.col > 0 && n.col < len(lab[0])-1 {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+// carve makes the cells c and n and the wall between them empty. c and n must be neighbours.
+func carve(lab [][]Block, c, n cell) {
+	lab[c.row][c.col] = BlockEmpty
+	lab[(c.row+n.row)/2][(c.col+n.col)/2] = BlockEmpty
+	lab[n.row][n.col] = BlockEmpty
+}
+
+// divisionGen is the recursive division algorithm.
+// It starts from an empty area and divides it into 2 parts with a wall having a hole in it, recursively.
+type divisionGen struct{}
+
+// Name implements Generator.Name().
+func (divisionGen) Name() string { return "division" }
+
+// Generate implements Generator.Generate().
+func (divisionGen) Generate(lab [][]Block, r *rand.Rand) {
+	rows, cols := len(lab), len(lab[0])
+
+	// Create a "frame":
+	for ri := range lab {
+		lab[ri][0] = BlockWall
+		lab[ri][cols-1] = BlockWall
+	}
+	for ci := range lab[0] {
+		lab[0][ci] = BlockWall
+		lab[rows-1][ci] = BlockWall
+	}
+
+	genLabArea(lab, r, 0, 0, cols-1, rows-1)
+}
+
+// genLabArea generates a random labyrinth inside the specified area, borders exclusive.
+// This is a recursive implementation, each iteration divides the area into 2 parts.
+func genLabArea(lab [][]Block, r *rand.Rand, x1, y1, x2, y2 int) {
+	dx, dy := x2-x1, y2-y1
+
+	// Exit condition from the recursion:
+	if dx <= 2 || dy <= 2 {
+		return
+	}
+
+	// Decide if we do a veritcal or horizontal split
+	var vert bool
+	if dy > dx {
+		vert = false
+	} else if dx > dy {
+		vert = true
+	} else if r.Intn(2) == 0 { // Area is square, choose randomly
+		vert = true
+	}
+
+	if vert {
+		// Add vertical split
+		var x int
+		if dx > 6 { // To avoid long straight paths, only use random in smaller areas
+			x = midWallPos(x1, x2)
+		} else {
+			x = rWallPos(r, x1, x2)
+		}
+		// A whole in it:
+		y := rPassPos(r, y1, y2)
+		for i := y1; i <= y2; i++ {
+			if i != y {
+				lab[i][x] = BlockWall
+			}
+		}
+
+		genLabArea(lab, r, x1, y1, x, y2)
+		genLabArea(lab, r, x, y1, x2, y2)
+	} else {
+		// Add horizontal split
+		var y int
+		if dy > 6 { // To avoid long straight paths, only use random in smaller areas
+			y = midWallPos(y1, y2)
+		} else {
+			y = rWallPos(r, y1, y2)
+		}
+		// A whole in it:
+		x := rPassPos(r, x1, x2)
+		for i := x1; i <= x2; i++ {
+			if i != x {
+				lab[y][i] = BlockWall
+			}
+		}
+
+		genLabArea(lab, r, x1, y1, x2, y)
+		genLabArea(lab, r, x1, y, x2, y2)
+	}
+}
+
+// backtrackerGen is the recursive backtracker algorithm (randomized depth-first search).
+// It produces long, winding passages with relatively few dead ends.
+type backtrackerGen struct{}
+
+// Name implements Generator.Name().
+func (backtrackerGen) Name() string { return "backtracker" }
+
+// Generate implements Generator.Generate().
+func (backtrackerGen) Generate(lab [][]Block, r *rand.Rand) {
+	fillWalls(lab)
+
+	start := cell{1, 1}
+	lab[start.row][start.col] = BlockEmpty
+	// Use an explicit stack instead of recursion, the labyrinth might be big
+	stack := []cell{start}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+
+		// Collect unvisited neighbours
+		var unvisited []cell
+		for _, n := range neighbours(lab, c) {
+			if lab[n.row][n.col] == BlockWall {
+				unvisited = append(unvisited, n)
+			}
+		}
+
+		if len(unvisited) == 0 {
+			stack = stack[:len(stack)-1] // Dead end, backtrack
+			continue
+		}
+
+		n := unvisited[r.Intn(len(unvisited))]
+		carve(lab, c, n)
+		stack = append(stack, n)
+	}
+}
+
+// primGen is the randomized Prim's algorithm.
+// It grows the labyrinth from a single cell by adding a random frontier cell in each step,
+// which results in many short dead ends.
+type primGen struct{}
+
+// Name implements Generator.Name().
+func (primGen) Name() string { return "prim" }
+
+// Generate implements Generator.Generate().
+func (primGen) Generate(lab [][]Block, r *rand.Rand) {
+	fillWalls(lab)
+
+	// inFrontier tells if a cell has already been added to the frontier
+	inFrontier := map[cell]bool{}
+	var frontier []cell
+
+	add := func(c cell) {
+		lab[c.row][c.col] = BlockEmpty
+		for _, n := range neighbours(lab, c) {
+			if lab[n.row][n.col] == BlockWall && !inFrontier[n] {
+				inFrontier[n] = true
+				frontier = append(frontier, n)
+			}
+		}
+	}
+
+	add(cell{1, 1})
+	for len(frontier) > 0 {
+		// Remove a random cell from the frontier
+		i := r.Intn(len(frontier))
+		c := frontier[i]
+		frontier[i] = frontier[len(frontier)-1]
+		frontier = frontier[:len(frontier)-1]
+
+		// Connect it to a random neighbour which is already part of the labyrinth
+		var in []cell
+		for _, n := range neighbours(lab, c) {
+			if lab[n.row][n.col] == BlockEmpty {
+				in = append(in, n)
+			}
+		}
+		carve(lab, c, in[r.Intn(len(in))])
+		add(c)
+	}
+}
+
+// kruskalGen is the randomized Kruskal's algorithm.
+// It removes walls in random order if the wall separates cells which are not yet connected.
+type kruskalGen struct{}
+
+// Name implements Generator.Name().
+func (kruskalGen) Name() string { return "kruskal" }
+
+// Generate implements Generator.Generate().
+func (kruskalGen) Generate(lab [][]Block, r *rand.Rand) {
+	fillWalls(lab)
+
+	// Disjoint-set forest of the cells
+	parent := map[cell]cell{}
+	var find func(c cell) cell
+	find = func(c cell) cell {
+		p := parent[c]
+		if p == c {
+			return c
+		}
+		p = find(p)
+		parent[c] = p // Path compression
+		return p
+	}
+
+	// Edges: pairs of neighbour cells; only right and down neighbours to have each edge once
+	type edge struct{ c, n cell }
+	var edges []edge
+	for _, c := range cells(lab) {
+		parent[c] = c
+		for _, n := range neighbours(lab, c) {
+			if n.row > c.row || n.col > c.col {
+				edges = append(edges, edge{c, n})
+			}
+		}
+	}
+
+	for _, i := range r.Perm(len(edges)) {
+		e := edges[i]
+		if pc, pn := find(e.c), find(e.n); pc != pn {
+			parent[pc] = pn
+			carve(lab, e.c, e.n)
+		}
+	}
+}
+
+// wilsonGen is Wilson's algorithm.
+// It uses loop-erased random walks, and generates an unbiased sample from the uniform distribution over all labyrinths.
+type wilsonGen struct{}
+
+// Name implements Generator.Name().
+func (wilsonGen) Name() string { return "wilson" }
+
+// Generate implements Generator.Generate().
+func (wilsonGen) Generate(lab [][]Block, r *rand.Rand) {
+	fillWalls(lab)
+
+	cs := cells(lab)
+	inLab := map[cell]bool{}
+
+	// Start with a random cell being part of the labyrinth
+	c := cs[r.Intn(len(cs))]
+	inLab[c] = true
+	lab[c.row][c.col] = BlockEmpty
+
+	// next stores the last exit direction of the cells visited by the random walk.
+	// Overwriting it erases the loops of the walk.
+	next := map[cell]cell{}
+
+	for _, start := range cs {
+		if inLab[start] {
+			continue
+		}
+
+		// Random walk until we hit the labyrinth
+		for c := start; !inLab[c]; {
+			ns := neighbours(lab, c)
+			n := ns[r.Intn(len(ns))]
+			next[c] = n
+			c = n
+		}
+
+		// Add the loop-erased path to the labyrinth
+		for c := start; !inLab[c]; c = next[c] {
+			inLab[c] = true
+			carve(lab, c, next[c])
+		}
+	}
+}
+
+// ellerGen is Eller's algorithm.
+// It generates the labyrinth row by row, only keeping track of the sets of cells in the current row.
+type ellerGen struct{}
+
+// Name implements Generator.Name().
+func (ellerGen) Name() string { return "eller" }
+
+// Generate implements Generator.Generate().
+func (ellerGen) Generate(lab [][]Block, r *rand.Rand) {
+	fillWalls(lab)
+
+	rows, cols := len(lab), len(lab[0])
+	// sets holds the set id of the cells of the current row (indexed by column)
+	sets := make([]int, cols)
+	nextSet := 1
+
+	// merge moves all cells of the current row from set "from" to set "to"
+	merge := func(from, to int) {
+		for ci := 1; ci < cols-1; ci += 2 {
+			if sets[ci] == from {
+				sets[ci] = to
+			}
+		}
+	}
+
+	for ri := 1; ri < rows-1; ri += 2 {
+		last := ri == rows-2
+
+		// Cells not yet in a set get their own, new set
+		for ci := 1; ci < cols-1; ci += 2 {
+			lab[ri][ci] = BlockEmpty
+			if sets[ci] == 0 {
+				sets[ci] = nextSet
+				nextSet++
+			}
+		}
+
+		// Randomly join adjacent cells of different sets (in the last row join all of them)
+		for ci := 1; ci < cols-3; ci += 2 {
+			if sets[ci] != sets[ci+2] && (last || r.Intn(2) == 0) {
+				merge(sets[ci+2], sets[ci])
+				lab[ri][ci+1] = BlockEmpty
+			}
+		}
+
+		if last {
+			break
+		}
+
+		// Each set must have at least one vertical connection downward
+		nextSets := make([]int, cols)
+		for ci := 1; ci < cols-1; {
+			// Collect the cells of the current run of the same set
+			// (sets are not necessarily continuous, but that is not a problem:
+			// each run gets at least one connection, which is more than enough)
+			set, run := sets[ci], []int{}
+			for ; ci < cols-1 && sets[ci] == set; ci += 2 {
+				run = append(run, ci)
+			}
+			connected := false
+			for i, c := range run {
+				if (i == len(run)-1 && !connected) || r.Intn(3) == 0 {
+					lab[ri+1][c] = BlockEmpty
+					nextSets[c] = set
+					connected = true
+				}
+			}
+		}
+		sets = nextSets
+	}
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"image"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// generate generates a labyrinth of the specified size with the specified generator and seed.
+func generate(gen Generator, rows, cols int, seed int64) [][]Block {
+	lab := make([][]Block, rows)
+	for i := range lab {
+		lab[i] = make([]Block, cols)
+	}
+	gen.Generate(lab, rand.New(rand.NewSource(seed)))
+	return lab
+}
+
+// reachable returns the number of free blocks reachable from the specified block.
+func reachable(lab [][]Block, from image.Point) int {
+	seen := map[image.Point]bool{from: true}
+	for queue := []image.Point{from}; len(queue) > 0; queue = queue[1:] {
+		for _, d := range dirDeltas {
+			if n := queue[0].Add(d); !seen[n] && isFree(lab, n) {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return len(seen)
+}
+
+func TestGenerators(t *testing.T) {
+	sizes := []struct{ rows, cols int }{{5, 5}, {15, 15}, {21, 33}, {33, 21}}
+	for _, gen := range Generators {
+		for _, size := range sizes {
+			for seed := int64(1); seed <= 5; seed++ {
+				lab := generate(gen, size.rows, size.cols, seed)
+				name := gen.Name()
+
+				cells, free := 0, 0
+				for ri, row := range lab {
+					for ci, block := range row {
+						frame := ri == 0 || ri == size.rows-1 || ci == 0 || ci == size.cols-1
+						switch {
+						case frame && block != BlockWall:
+							t.Fatalf("%s %dx%d seed %d: frame is open at row %d col %d", name, size.rows, size.cols, seed, ri, ci)
+						case ri%2 == 1 && ci%2 == 1 && block != BlockEmpty:
+							t.Fatalf("%s %dx%d seed %d: wall in a cell at row %d col %d", name, size.rows, size.cols, seed, ri, ci)
+						case ri%2 == 0 && ci%2 == 0 && block != BlockWall:
+							t.Fatalf("%s %dx%d seed %d: no wall at even row %d col %d", name, size.rows, size.cols, seed, ri, ci)
+						}
+						if ri%2 == 1 && ci%2 == 1 {
+							cells++
+						}
+						if block == BlockEmpty {
+							free++
+						}
+					}
+				}
+
+				// A perfect maze: all cells are connected, and it's a tree (cells-1 passages between the cells)
+				if n := reachable(lab, image.Pt(1, 1)); n != free {
+					t.Errorf("%s %dx%d seed %d: %d of %d free blocks reachable", name, size.rows, size.cols, seed, n, free)
+				}
+				if free != 2*cells-1 {
+					t.Errorf("%s %dx%d seed %d: %d free blocks, a perfect maze has %d", name, size.rows, size.cols, seed, free, 2*cells-1)
+				}
+
+				if !reflect.DeepEqual(lab, generate(gen, size.rows, size.cols, seed)) {
+					t.Errorf("%s %dx%d seed %d: not reproducible from the seed", name, size.rows, size.cols, seed)
+				}
+			}
+		}
+	}
+}
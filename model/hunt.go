@@ -0,0 +1,74 @@
+package model
+
+import (
+	"image"
+	"time"
+)
+
+// HuntTime is the default time limit of hunts: the hunter has to catch Gopher within this time.
+var HuntTime = 2 * time.Minute
+
+// HunterBehavior is the Behavior of the Bulldog controlled by a player (the hunter):
+// it moves along the waypoints clicked by the player, and stands still if there are none.
+// It is not a built-in Behavior, it cannot be selected by name.
+type HunterBehavior struct {
+	// Waypoints are the queued target blocks,
+	// each is in the same row or column as the previous one with a free straight passage to it.
+	Waypoints []image.Point
+}
+
+func (*HunterBehavior) Name() string { return "hunter" }
+
+func (h *HunterBehavior) Next(g *Game, bd *Bulldog) image.Point {
+	if len(h.Waypoints) == 0 {
+		return bd.Block()
+	}
+	next := h.Waypoints[0]
+	h.Waypoints = h.Waypoints[:copy(h.Waypoints, h.Waypoints[1:])]
+	return next
+}
+
+// JoinHunter makes a new player join the game as the hunter, controlling a Bulldog.
+// The hunter takes part from the next game (the current game is to be restarted to start the hunt).
+// Returns false if the game already has a hunter, or if campaign mode is enabled (hunts are single games).
+func (g *Game) JoinHunter() bool {
+	if g.Hunter || len(g.Campaign) > 0 {
+		return false
+	}
+	g.Hunter = true
+	return true
+}
+
+// LeaveHunter releases the hunter who left the game (e.g. its session was discarded).
+// The current hunt goes on until its time limit, the next game is not a hunt.
+func (g *Game) LeaveHunter() {
+	g.Hunter = false
+}
+
+// HunterBulldog returns the Bulldog controlled by the hunter, nil if the current game is not a hunt.
+func (g *Game) HunterBulldog() *Bulldog {
+	if !g.Hunting || len(g.Bulldogs) == 0 {
+		return nil
+	}
+	return g.Bulldogs[0]
+}
+
+// HuntOver tells if the hunt is over: the time is up or no Gopher is racing anymore.
+// If the time limit is reached, TimeUp is set.
+func (g *Game) HuntOver() bool {
+	if g.Racing() && g.LevelTime >= g.HuntTime {
+		g.TimeUp = true
+	}
+	return g.TimeUp || !g.Racing()
+}
+
+// HuntResult returns the lines of text to be displayed on the result screen of a hunt.
+func (g *Game) HuntResult() []string {
+	switch {
+	case g.Finished():
+		return []string{"Gopher wins!", "", "Gopher reached the exit"}
+	case g.TimeUp:
+		return []string{"Gopher wins!", "", "Time is up"}
+	}
+	return []string{"Bulldog wins!", "", "Gopher was caught in " + FormatDuration(g.LevelTime)}
+}
@@ -9,20 +9,24 @@ import (
 	"image/draw"
 	"image/png"
 	"io/ioutil"
+	"math"
 )
 
 // Tells if the embedded images are to be used. If false, images from files will be loaded.
 const useEmbeddedImages = true
 
-// Image of the labyrinth
-var LabImg *image.RGBA
-
 // Gopher images for each direction, each has zero Min point
 var GopherImgs []*image.RGBA = make([]*image.RGBA, DirLength)
 
 // Dead Gopher image.
 var DeadImg *image.RGBA
 
+// Gopher images of the players for each direction, tinted with the colors of the players
+var PlayerGopherImgs = make([][]*image.RGBA, MaxPlayers)
+
+// Dead Gopher images of the players, tinted with the colors of the players
+var PlayerDeadImgs = make([]*image.RGBA, MaxPlayers)
+
 // Bulldog images for each direction, each has zero Min point
 var BulldogImgs []*image.RGBA = make([]*image.RGBA, DirLength)
 
@@ -56,6 +60,36 @@ func init() {
 
 	TargetImg = loadImg("marker.png", false)
 	WonImg = loadImg("won.png", false)
+
+	PlayerGopherImgs[0], PlayerDeadImgs[0] = GopherImgs, DeadImg
+	for i := 1; i < MaxPlayers; i++ {
+		PlayerGopherImgs[i] = make([]*image.RGBA, DirLength)
+		for d, img := range GopherImgs {
+			PlayerGopherImgs[i][d] = tintImg(img, PlayerColors[i])
+		}
+		PlayerDeadImgs[i] = tintImg(DeadImg, PlayerColors[i])
+	}
+}
+
+// tintImg returns a copy of the specified Gopher image with the body of the Gopher recolored to the specified color.
+// The body is the bluish part of the image, its shading is preserved.
+func tintImg(src *image.RGBA, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(src.Rect)
+	copy(img.Pix, src.Pix)
+
+	body := PlayerColors[0]
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b := int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2])
+		if b-r < 60 {
+			continue // Not part of the body
+		}
+		// Shade relative to the original body color
+		shade := float64(g+b) / float64(int(body.G)+int(body.B))
+		for j, v := range [...]uint8{c.R, c.G, c.B} {
+			img.Pix[i+j] = uint8(math.Min(255, float64(v)*shade))
+		}
+	}
+	return img
 }
 
 // loadImg loads a PNG image from the specified file, and converts it to image.RGBA and makes sure image has zero Min point.
@@ -5,14 +5,10 @@ import (
 	"image/draw"
 	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Mutex to be used to synchronize model modifications
-var Mutex sync.Mutex
-
-// The model/data of the labyrinth
-var Lab [][]Block
-
 // MovingObj is a struct describing a moving object.
 type MovingObj struct {
 	// The position in the labyrinth in pixel coordinates
@@ -30,48 +26,55 @@ type MovingObj struct {
 	Imgs []*image.RGBA
 }
 
-// DrawImg draws the image of the MovingObj to the LabImg.
-func (m *MovingObj) DrawImg() {
-	m.DrawWithImg(m.Imgs[m.Direction])
-}
-
-// EraseImg erases the image of the MovingObj from the LabImg by drawing empty block to it.
-func (m *MovingObj) EraseImg() {
-	m.DrawWithImg(EmptyImg)
+// DrawImg draws the image of the MovingObj onto the specified image.
+func (m *MovingObj) DrawImg(dst draw.Image) {
+	m.DrawWithImg(dst, m.Imgs[m.Direction])
 }
 
-// DrawWithImage draws the specified image at the position of the moving object onto the LabImg.
-func (m *MovingObj) DrawWithImg(img image.Image) {
-	DrawImgAt(img, int(m.Pos.X), int(m.Pos.Y))
+// DrawWithImage draws the specified image at the position of the moving object onto dst.
+func (m *MovingObj) DrawWithImg(dst draw.Image, img image.Image) {
+	DrawImgAt(dst, img, int(m.Pos.X), int(m.Pos.Y))
 }
 
-// DrawImgAt draws the specified image at the specified position which specifies the center of the area to draw.
+// DrawImgAt draws the specified image onto dst at the specified position which specifies the center of the area to draw.
 // The size of the image draw is the block size.
-func DrawImgAt(img image.Image, x, y int) {
+func DrawImgAt(dst draw.Image, img image.Image, x, y int) {
 	r := image.Rect(0, 0, BlockSize, BlockSize).Add(image.Point{x - BlockSize/2, y - BlockSize/2})
-	draw.Draw(LabImg, r, img, image.Point{}, draw.Over)
+	draw.Draw(dst, r, img, image.Point{}, draw.Over)
 }
 
-// Gopher is our hero, the moving object the user can control.
-var Gopher = new(MovingObj)
-
-// Dead tells if Gopher died
-var Dead bool
-
-// Tells if we won
-var Won bool
+// NewGame describes the parameters of a new game.
+type NewGame struct {
+	// Algorithm is the name of the labyrinth generator algorithm, empty means the default (Algorithm).
+	Algorithm string `json:"algorithm"`
 
-// For Gopher we maintain multiple target positions which define a path on which Gopher will move along
-var TargetPoss = make([]image.Point, 0, 20)
+	// Seed is the seed of the random number generator of the game, 0 means the default (Seed).
+	// Encoded as string in JSON because JavaScript cannot represent all int64 values.
+	Seed int64 `json:"seed,string"`
 
-// Slice of Bulldogs, the ancient enemy of Gophers.
-var Bulldogs []*MovingObj
-
-// Exit position
-var ExitPos = image.Point{}
+	// Level is the index of the level to start in campaign mode (0 restarts the campaign).
+	// It is set by the engine when a level is won, the level requested by clients is ignored.
+	Level int `json:"level"`
+}
 
-// Channel to signal new game
-var NewGameCh = make(chan int, 1)
+// Normalize replaces the unspecified fields of the new game parameters with their defaults.
+// If no seed is specified and there is no default, a random seed is chosen.
+// campaignLen is the number of levels of the campaign (0 if campaign mode is disabled).
+func (ng *NewGame) Normalize(campaignLen int) {
+	if ng.Algorithm == "" {
+		ng.Algorithm = Algorithm
+	}
+	if ng.Seed == 0 {
+		ng.Seed = Seed
+	}
+	if ng.Seed == 0 {
+		// Keep it small and positive so it's easy to read and share
+		ng.Seed = 1 + time.Now().UnixNano()%(1<<31-1)
+	}
+	if ng.Level < 0 || ng.Level >= campaignLen {
+		ng.Level = 0
+	}
+}
 
 // Constant for the right Mouse button value in the Click struct.
 // Button value for left and middle may not be the same for older browsers, but right button always has this value.
@@ -80,175 +83,344 @@ const MouseBtnRight = 2
 // Click describes a mouse click.
 type Click struct {
 	// X, Y are the mouse coordinates in pixel, in the coordinate system of the Labyrinth
-	X, Y int
+	X int `json:"x"`
+	Y int `json:"y"`
 	// Btn is the mouse button
-	Btn int
+	Btn int `json:"b"`
+	// PathFinding tells if any reachable block can be clicked (the shortest path to it is found),
+	// else only blocks in the same row or column with a free straight passage to them
+	PathFinding bool `json:"p,omitempty"`
+	// Player is the index of the player who clicked
+	Player int `json:"pl,omitempty"`
+	// Hunter tells if the hunter clicked (Player is not used then)
+	Hunter bool `json:"h,omitempty"`
+}
+
+// Key describes a key event of a direction key (keyboard control).
+type Key struct {
+	// Dir is the direction of the key
+	Dir Dir `json:"d"`
+	// Pressed tells if the key was pressed (else released)
+	Pressed bool `json:"p,omitempty"`
+	// Player is the index of the player who pressed the key
+	Player int `json:"pl,omitempty"`
 }
 
-// Channel to receive mouse clicks on (view package sends, ctrl package (engine) processes them)
-var ClickCh = make(chan Click, 10)
+// Game is a game instance: its configuration and its complete state.
+//
+// Each game is simulated by its own engine goroutine (see the ctrl package), and games are independent of each other.
+// Fields must only be accessed while holding Mutex, which the engine holds while simulating.
+// Other goroutines (e.g. the views) are to read the published snapshots of the game instead (see Snapshot).
+// Inputs of the game are to be sent on its channels.
+type Game struct {
+	// Mutex to be used to synchronize the modifications of the game
+	Mutex sync.Mutex
+
+	// snapshot is the latest published Snapshot, it is not protected by Mutex
+	snapshot atomic.Value
+
+	// snapshotReady is closed when the first Snapshot is published
+	snapshotReady chan struct{}
+
+	// Configuration of the game, initialized from the package level defaults
+	// (campaign levels and replays change them).
+
+	// Rows and Cols are the number of rows and columns in the Labyrinth
+	Rows, Cols int
+	// LabWidth and LabHeight are the size of the labyrinth's image in pixels
+	LabWidth, LabHeight int
+	// V is the moving speed of Gopher and the Bulldogs in pixel/sec
+	V float64
+	// BulldogDensity is the number of Bulldogs in an area of 1,000 blocks
+	BulldogDensity float64
+	// Braid is the fraction of dead ends to remove from generated labyrinths
+	Braid float64
+	// BulldogBehaviors are the names of the Behaviors of the Bulldogs
+	BulldogBehaviors []string
+	// FixedLevel is the level to play instead of generated labyrinths, if not nil
+	FixedLevel *Level
+	// Campaign is the progression table of the campaign, empty if campaign mode is disabled
+	Campaign []Stage
+	// HuntTime is the time limit of hunts
+	HuntTime time.Duration
+
+	// CurGame holds the (normalized) parameters of the current game
+	CurGame NewGame
+
+	// Rand is the random number generator of the game.
+	// Everything random in a game must use this, so a game can be reproduced from its seed.
+	Rand *rand.Rand
+
+	// CurLevel is the level of the current game
+	CurLevel *Level
+
+	// The model/data of the labyrinth
+	Lab [][]Block
+
+	// Image of the static background of the labyrinth (floor, walls and the exit), nil if the game is headless.
+	// It is drawn once when the game is initialized, the moving objects are drawn onto copies of it (see Snapshot.Compose).
+	LabImg *image.RGBA
+
+	// Headless tells if the game is run without rendering (no image of the labyrinth is created)
+	Headless bool
+
+	// Players are the players racing in the labyrinth, each controlling its own Gopher (our heroes)
+	Players []*Player
+
+	// Joined is the number of players joined the game (see Join())
+	Joined int
+
+	// Left tells which of the joined players left the game, their slots are reused by new players (see Leave())
+	Left [MaxPlayers]bool
+
+	// Hunter tells if a player joined the game as the hunter (see JoinHunter())
+	Hunter bool
+
+	// Hunting tells if the current game is a hunt: a Bulldog is controlled by the hunter
+	Hunting bool
+
+	// TimeUp tells if the time limit of the hunt is reached
+	TimeUp bool
+
+	// Bulldogs, the ancient enemy of Gophers
+	Bulldogs []*Bulldog
+
+	// Exit position in pixel coordinates
+	ExitPos image.Point
+
+	// Won tells if we won: a player reached the exit and no one else is racing.
+	// In a hunt it tells if the hunt is over (whoever won).
+	Won bool
+
+	// WonLines are the lines of the hunt and race results and the level complete screen
+	// (in hunt, multiplayer and campaign mode)
+	WonLines []string
+
+	// Paused tells if the game is paused (nothing moves)
+	Paused bool
+
+	// Tick is the number of simulation ticks since the game started
+	Tick int64
+
+	// Frame is the number of frames rendered by the engine: a new Snapshot is published for each frame
+	// (views are to be composed again when it changes)
+	Frame int64
+
+	// Campaign state
+	CampaignLevel int
+	LevelTime     time.Duration
+	CampaignTime  time.Duration
+
+	// Recording is the replay being recorded of the current game, nil while a replay is played back
+	Recording *Replay
+
+	// Playback is the replay being played back, nil if not in replay mode
+	Playback *Replay
+
+	// Channel to signal new game
+	NewGameCh chan NewGame
+
+	// Channel to receive pause (true) and resume (false) commands on
+	PauseCh chan bool
+
+	// Channel to receive mouse clicks on (view package sends, ctrl package (engine) processes them)
+	ClickCh chan Click
+
+	// Channel to receive key events on (view package sends, ctrl package (engine) processes them)
+	KeyCh chan Key
+
+	// Channel to receive replay commands on (view package sends, ctrl package (engine) processes them)
+	ReplayCh chan ReplayCmd
+
+	// Events is the bus of the events of the game (ctrl package (engine) publishes, other packages subscribe).
+	// It is not protected by Mutex, it is safe for concurrent use.
+	Events *EventBus
+
+	// Quit is closed to stop the engine of the game
+	Quit chan struct{}
+}
+
+// CreateGame creates a new game instance with the default configuration.
+// The game is not initialized, send a NewGame on its NewGameCh to start it.
+func CreateGame() *Game {
+	g := &Game{
+		Joined:    1,
+		NewGameCh: make(chan NewGame, 1),
+		PauseCh:   make(chan bool, 10),
+		ClickCh:   make(chan Click, 10),
+		KeyCh:     make(chan Key, 10),
+		ReplayCh:  make(chan ReplayCmd, 1),
+		Events:    &EventBus{},
+		Quit:      make(chan struct{}),
+
+		snapshotReady: make(chan struct{}),
+	}
+	g.ResetConfig()
+	return g
+}
+
+// ResetConfig resets the configuration of the game to the package level defaults.
+func (g *Game) ResetConfig() {
+	g.Rows, g.Cols = Rows, Cols
+	g.LabWidth, g.LabHeight = LabWidth, LabHeight
+	g.V = V
+	g.BulldogDensity = BulldogDensity
+	g.Braid = Braid
+	g.BulldogBehaviors = BulldogBehaviors
+	g.FixedLevel = FixedLevel
+	g.Campaign = Campaign
+	g.HuntTime = HuntTime
+}
 
 // InitNew initializes a new game.
-func InitNew() {
-	LabImg = image.NewRGBA(image.Rect(0, 0, LabWidth, LabHeight))
+func (g *Game) InitNew(ng NewGame) {
+	ng.Normalize(len(g.Campaign))
+	g.CurGame = ng
+	// Each level of a campaign has its own labyrinth, derive their seeds from the seed of the game
+	g.Rand = rand.New(rand.NewSource(ng.Seed + int64(ng.Level)))
+
+	if len(g.Campaign) > 0 {
+		g.initStage(ng.Level)
+	}
 
-	Bulldogs = make([]*MovingObj, int(float64(Rows*Cols)*BulldogDensity/1000))
+	g.Won = false
+	g.TimeUp = false
+	g.Paused = false
+	g.Tick = 0
+	g.LevelTime = 0
 
-	Dead = false
-	Won = false
+	if g.FixedLevel != nil {
+		// Spawns are overwritten in initBulldogs(), so make a copy
+		l := *g.FixedLevel
+		g.CurLevel = &l
+	} else {
+		g.CurLevel = g.genLevel(GeneratorByName(ng.Algorithm))
+	}
+	g.Lab = g.CurLevel.Lab
 
-	initLab()
+	g.Hunting = g.Hunter
+	if g.Playback != nil {
+		// Hunts are recorded with their hunter
+		g.Hunting = g.Playback.Hunter
+	}
 
-	initGopher()
+	g.initPlayers()
 
-	initBulldogs()
+	g.initBulldogs()
 
-	initLabImg()
+	g.ExitPos = BlockCenter(g.CurLevel.Exit)
 
-	ExitPos.X, ExitPos.Y = (Cols-2)*BlockSize+BlockSize/2, (Rows-2)*BlockSize+BlockSize/2
+	if !g.Headless {
+		g.LabImg = image.NewRGBA(image.Rect(0, 0, g.LabWidth, g.LabHeight))
+		g.initLabImg()
+	}
 }
 
-// initLab initializes and generates a new Labyrinth.
-func initLab() {
-	Lab = make([][]Block, Rows)
-	for i := range Lab {
-		Lab[i] = make([]Block, Cols)
+// genLevel generates a new level with a new Labyrinth using the specified Generator.
+// Gopher starts at the top left corner, the exit is at the bottom right corner.
+func (g *Game) genLevel(gen Generator) *Level {
+	l := &Level{Lab: make([][]Block, g.Rows)}
+	for i := range l.Lab {
+		l.Lab[i] = make([]Block, g.Cols)
 	}
 
 	// Zero value of the labyrinth is full of empty blocks
 
 	// generate labyrinth
-	genLab()
+	gen.Generate(l.Lab, g.Rand)
+	if g.Braid > 0 {
+		braid(l.Lab, g.Braid, g.Rand)
+	}
+
+	l.Start = image.Pt(1, 1)
+	l.Exit = image.Pt(g.Cols-2, g.Rows-2)
+
+	return l
 }
 
-// initGopher initializes Gopher.
-func initGopher() {
-	// Position Gopher to top left corner
-	Gopher.Pos.X = BlockSize + BlockSize/2
-	Gopher.Pos.Y = Gopher.Pos.X
-	Gopher.Direction = DirRight
-	Gopher.TargetPos.X, Gopher.TargetPos.Y = int(Gopher.Pos.X), int(Gopher.Pos.Y)
-	Gopher.Imgs = GopherImgs
-
-	// Throw away queued targets
-	TargetPoss = TargetPoss[0:0]
+// BlockCenter returns the center of the block specified by its block coordinates, in pixel coordinates.
+func BlockCenter(p image.Point) image.Point {
+	return image.Pt(p.X*BlockSize+BlockSize/2, p.Y*BlockSize+BlockSize/2)
 }
 
 // initBulldogs creates and initializes the Bulldogs.
-func initBulldogs() {
-	for i := 0; i < len(Bulldogs); i++ {
-		bd := new(MovingObj)
-		Bulldogs[i] = bd
-
-		// Place bulldog at a random position
-		var row, col = int(Gopher.Pos.Y) / BlockSize, int(Gopher.Pos.X) / BlockSize
-		// Give some space to Gopher: do not generate Bulldogs too close:
-		for gr, gc := row, col; (row-gr)*(row-gr) <= 16 && (col-gc)*(col-gc) <= 16; row, col = rPassPos(0, Rows), rPassPos(0, Cols) {
+// If the level has spawn positions, a Bulldog is placed at each of them,
+// else Bulldogs are placed randomly according to BulldogDensity and their positions are recorded as the spawns of the level.
+// Behaviors are assigned to the Bulldogs from BulldogBehaviors in turns.
+// In a hunt the first Bulldog is controlled by the hunter (there is at least one Bulldog).
+func (g *Game) initBulldogs() {
+	spawns := g.CurLevel.Spawns
+	if len(spawns) == 0 {
+		n := int(float64(g.Rows*g.Cols) * g.BulldogDensity / 1000)
+		if g.Hunting && n == 0 {
+			n = 1
+		}
+		spawns = make([]image.Point, n)
+
+		gr, gc := g.CurLevel.Start.Y, g.CurLevel.Start.X
+		for i := range spawns {
+			// Place bulldog at a random free position
+			var row, col int
+			for try := 0; ; try++ {
+				row, col = g.Rand.Intn(g.Rows), g.Rand.Intn(g.Cols)
+				if g.Lab[row][col] != BlockEmpty || row == gr && col == gc {
+					continue
+				}
+				// Give some space to Gopher: do not generate Bulldogs too close
+				// (but give up on this after many tries, small levels may not have enough space):
+				if (row-gr)*(row-gr) > 16 || (col-gc)*(col-gc) > 16 || try > 1000 {
+					break
+				}
+			}
+			spawns[i] = image.Pt(col, row)
+		}
+		g.CurLevel.Spawns = spawns
+	}
+
+	g.Bulldogs = make([]*Bulldog, len(spawns))
+	for i, spawn := range spawns {
+		bd := &Bulldog{
+			MovingObj: new(MovingObj),
+			Behavior:  NewBehavior(g.BulldogBehaviors[i%len(g.BulldogBehaviors)]),
+			Spawn:     spawn,
+		}
+		g.Bulldogs[i] = bd
+		if i == 0 && g.Hunting {
+			bd.Behavior = &HunterBehavior{}
 		}
 
-		bd.Pos.X = float64(col*BlockSize + BlockSize/2)
-		bd.Pos.Y = float64(row*BlockSize + BlockSize/2)
+		pos := BlockCenter(spawn)
+		bd.Pos.X = float64(pos.X)
+		bd.Pos.Y = float64(pos.Y)
 
-		bd.TargetPos.X, bd.TargetPos.Y = int(bd.Pos.X), int(bd.Pos.Y)
+		bd.TargetPos = pos
 		bd.Imgs = BulldogImgs
 	}
 }
 
-// initLabImg initializes and draws the image of the Labyrinth.
-func initLabImg() {
+// initLabImg initializes and draws the background image of the Labyrinth.
+func (g *Game) initLabImg() {
 	// Clear the labyrinth image
-	draw.Draw(LabImg, LabImg.Bounds(), EmptyImg, image.Pt(0, 0), draw.Over)
+	draw.Draw(g.LabImg, g.LabImg.Bounds(), EmptyImg, image.Pt(0, 0), draw.Over)
 
 	// Draw walls
 	zeroPt := image.Point{}
-	for ri, row := range Lab {
+	for ri, row := range g.Lab {
 		for ci, block := range row {
 			if block == BlockWall {
 				x, y := ci*BlockSize, ri*BlockSize
 				rect := image.Rect(x, y, x+BlockSize, y+BlockSize)
-				draw.Draw(LabImg, rect, WallImg, zeroPt, draw.Over)
+				draw.Draw(g.LabImg, rect, WallImg, zeroPt, draw.Over)
 			}
 		}
 	}
-}
-
-// genLab generates a random labyrinth.
-func genLab() {
-	// Create a "frame":
-	for ri := range Lab {
-		Lab[ri][0] = BlockWall
-		Lab[ri][Cols-1] = BlockWall
-	}
-	for ci := range Lab[0] {
-		Lab[0][ci] = BlockWall
-		Lab[Rows-1][ci] = BlockWall
-	}
-
-	genLabArea(0, 0, Rows-1, Cols-1)
-}
-
-// genLabArea generates a random labyrinth inside the specified area, borders exclusive.
-// This is a recursive implementation, each iteration divides the area into 2 parts.
-func genLabArea(x1, y1, x2, y2 int) {
-	dx, dy := x2-x1, y2-y1
-
-	// Exit condition from the recursion:
-	if dx <= 2 || dy <= 2 {
-		return
-	}
-
-	// Decide if we do a veritcal or horizontal split
-	var vert bool
-	if dy > dx {
-		vert = false
-	} else if dx > dy {
-		vert = true
-	} else if rand.Intn(2) == 0 { // Area is square, choose randomly
-		vert = true
-	}
-
-	if vert {
-		// Add vertical split
-		var x int
-		if dx > 6 { // To avoid long straight paths, only use random in smaller areas
-			x = midWallPos(x1, x2)
-		} else {
-			x = rWallPos(x1, x2)
-		}
-		// A whole in it:
-		y := rPassPos(y1, y2)
-		for i := y1; i <= y2; i++ {
-			if i != y {
-				Lab[i][x] = BlockWall
-			}
-		}
 
-		genLabArea(x1, y1, x, y2)
-		genLabArea(x, y1, x2, y2)
-	} else {
-		// Add horizontal split
-		var y int
-		if dy > 6 { // To avoid long straight paths, only use random in smaller areas
-			y = midWallPos(y1, y2)
-		} else {
-			y = rWallPos(y1, y2)
-		}
-		// A whole in it:
-		x := rPassPos(x1, x2)
-		for i := x1; i <= x2; i++ {
-			if i != x {
-				Lab[y][i] = BlockWall
-			}
-		}
-
-		genLabArea(x1, y1, x2, y)
-		genLabArea(x1, y, x2, y2)
-	}
+	DrawImgAt(g.LabImg, ExitImg, g.ExitPos.X, g.ExitPos.Y)
 }
 
 // rWallPos returns a random wall position which is an even number between the specified min and max.
-func rWallPos(min, max int) int {
-	return min + (rand.Intn((max-min)/2-1)+1)*2
+func rWallPos(r *rand.Rand, min, max int) int {
+	return min + (r.Intn((max-min)/2-1)+1)*2
 }
 
 // midWallPos returns the wall position being at the middle of the specified min and max.
@@ -262,6 +434,6 @@ func midWallPos(min, max int) int {
 }
 
 // rPassPos returns a random passage position which is an odd number between the specified min and max.
-func rPassPos(min, max int) int {
-	return rWallPos(min, max+2) - 1
+func rPassPos(r *rand.Rand, min, max int) int {
+	return rWallPos(r, min, max+2) - 1
 }
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+// Characters of the plain-text level format.
+//
+// A level is a rectangle of these characters, one line for each row of the labyrinth.
+// The outer frame must be wall, and there must be exactly one start and one exit position.
+// Bulldog spawn positions are optional; if there are none, Bulldogs are placed randomly
+// according to the Bulldog density.
+const (
+	LevelWall    = '#'
+	LevelEmpty   = '.'
+	LevelStart   = 'S'
+	LevelExit    = 'E'
+	LevelBulldog = 'B'
+)
+
+// Level is a labyrinth along with the positions of the game objects in it.
+// Positions are in block coordinates (X is the column, Y is the row).
+type Level struct {
+	// The labyrinth
+	Lab [][]Block
+
+	// Start is the starting position of Gopher
+	Start image.Point
+
+	// Exit is the exit position
+	Exit image.Point
+
+	// Spawns are the starting positions of the Bulldogs
+	Spawns []image.Point
+}
+
+// FixedLevel is the default level to play instead of generated labyrinths, if not nil.
+var FixedLevel *Level
+
+// Rows returns the number of rows of the level.
+func (l *Level) Rows() int {
+	return len(l.Lab)
+}
+
+// Cols returns the number of columns of the level.
+func (l *Level) Cols() int {
+	return len(l.Lab[0])
+}
+
+// LoadLevel loads a level from the specified file.
+func LoadLevel(name string) (*Level, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseLevel(f)
+}
+
+// ParseLevel parses a level in plain-text format from the specified reader.
+func ParseLevel(r io.Reader) (*Level, error) {
+	l := &Level{}
+	var hasStart, hasExit bool
+
+	scanner := bufio.NewScanner(r)
+	var emptyLine bool
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(line) == 0 {
+			emptyLine = true
+			continue
+		}
+		if emptyLine {
+			return nil, fmt.Errorf("line %d: empty lines are only allowed at the end", lineNum)
+		}
+		if len(l.Lab) > 0 && len(line) != l.Cols() {
+			return nil, fmt.Errorf("line %d: length %d differs from the length of the first line (%d)", lineNum, len(line), l.Cols())
+		}
+
+		ri := len(l.Lab)
+		row := make([]Block, len(line))
+		for ci, ch := range line {
+			pos := image.Pt(ci, ri)
+			switch ch {
+			case LevelWall:
+				row[ci] = BlockWall
+			case LevelEmpty:
+			case LevelStart:
+				if hasStart {
+					return nil, fmt.Errorf("line %d: multiple start positions", lineNum)
+				}
+				hasStart, l.Start = true, pos
+			case LevelExit:
+				if hasExit {
+					return nil, fmt.Errorf("line %d: multiple exit positions", lineNum)
+				}
+				hasExit, l.Exit = true, pos
+			case LevelBulldog:
+				l.Spawns = append(l.Spawns, pos)
+			default:
+				return nil, fmt.Errorf("line %d: invalid character: %q", lineNum, ch)
+			}
+		}
+		l.Lab = append(l.Lab, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(l.Lab) < 3 || l.Cols() < 3 {
+		return nil, errors.New("level must have at least 3 rows and 3 columns")
+	}
+	if !hasStart {
+		return nil, errors.New("level has no start position")
+	}
+	if !hasExit {
+		return nil, errors.New("level has no exit position")
+	}
+
+	// Check the frame:
+	for ri, row := range l.Lab {
+		for ci, block := range row {
+			if (ri == 0 || ri == l.Rows()-1 || ci == 0 || ci == l.Cols()-1) && block != BlockWall {
+				return nil, fmt.Errorf("line %d: outer frame must be wall", ri+1)
+			}
+		}
+	}
+
+	if ShortestPath(l.Lab, l.Start, l.Exit) == nil {
+		return nil, errors.New("exit is not reachable from the start position")
+	}
+
+	return l, nil
+}
+
+// Write writes the level in plain-text format to the specified writer.
+func (l *Level) Write(w io.Writer) error {
+	lines := make([][]byte, l.Rows())
+	for ri, row := range l.Lab {
+		line := make([]byte, len(row)+1)
+		for ci, block := range row {
+			if block == BlockWall {
+				line[ci] = LevelWall
+			} else {
+				line[ci] = LevelEmpty
+			}
+		}
+		line[len(row)] = '\n'
+		lines[ri] = line
+	}
+
+	for _, p := range l.Spawns {
+		lines[p.Y][p.X] = LevelBulldog
+	}
+	lines[l.Start.Y][l.Start.X] = LevelStart
+	lines[l.Exit.Y][l.Exit.X] = LevelExit
+
+	_, err := w.Write(bytes.Join(lines, nil))
+	return err
+}
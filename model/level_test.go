@@ -0,0 +1,48 @@
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoadExampleLevel(t *testing.T) {
+	l, err := LoadLevel("../levels/example.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Spawns) == 0 {
+		t.Error("example level has no Bulldog spawns")
+	}
+
+	// Writing and parsing it again must give the same level
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	l2, err := ParseLevel(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l2.Start != l.Start || l2.Exit != l.Exit || len(l2.Spawns) != len(l.Spawns) {
+		t.Errorf("round trip changed the level: %+v, %+v", l2, l)
+	}
+}
+
+func TestParseLevelErrors(t *testing.T) {
+	cases := []struct {
+		name, level string
+	}{
+		{"no start", "#####\n#..E#\n#####\n"},
+		{"no exit", "#####\n#S..#\n#####\n"},
+		{"open frame", "#####\n#S.E.\n#####\n"},
+		{"invalid character", "#####\n#SxE#\n#####\n"},
+		{"ragged", "#####\n#S.E#\n####\n"},
+		{"unreachable exit", "#####\n#S#E#\n#####\n"},
+	}
+	for _, c := range cases {
+		if _, err := ParseLevel(strings.NewReader(c.level)); err == nil {
+			t.Errorf("%s: level accepted", c.name)
+		}
+	}
+}
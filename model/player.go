@@ -0,0 +1,177 @@
+package model
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"time"
+)
+
+// MaxPlayers is the maximum number of players racing in the same labyrinth.
+const MaxPlayers = 4
+
+// Names and colors of the players (their Gophers), the Gopher of the first player has its original color.
+var (
+	PlayerNames  = []string{"Blue", "Red", "Green", "Yellow"}
+	PlayerColors = []color.RGBA{{106, 215, 229, 0xff}, {236, 88, 80, 0xff}, {110, 214, 96, 0xff}, {240, 208, 72, 0xff}}
+)
+
+// Player is a player of the game: a Gopher controlled by a user.
+type Player struct {
+	// Gopher of the player
+	*MovingObj
+
+	// DeadImg is the image of the dead Gopher of the player
+	DeadImg *image.RGBA
+
+	// For Gopher we maintain multiple target positions which define a path on which Gopher will move along
+	TargetPoss []image.Point
+
+	// Dead tells if the Gopher of the player died
+	Dead bool
+
+	// Finished tells if the player reached the exit
+	Finished bool
+
+	// FinishTime is the level time when the player reached the exit
+	FinishTime time.Duration
+
+	// Forfeited tells if the player left the game (see Game.Leave) before finishing the race
+	Forfeited bool
+}
+
+// Racing tells if the player is still in the race: neither dead nor finished, and did not forfeit.
+func (p *Player) Racing() bool {
+	return !p.Dead && !p.Finished && !p.Forfeited
+}
+
+// Block returns the block where the Gopher of the player is, in block coordinates.
+func (p *Player) Block() image.Point {
+	return image.Pt(int(p.Pos.X)/BlockSize, int(p.Pos.Y)/BlockSize)
+}
+
+// Join adds a new player to the game, reusing the slot of a player who left if there is one.
+// The new player takes part from the next game (the current game is to be restarted to start a new race).
+// Returns the index of the new player, -1 if the game is full.
+func (g *Game) Join() int {
+	for i := 0; i < g.Joined; i++ {
+		if g.Left[i] {
+			g.Left[i] = false
+			return i
+		}
+	}
+	if g.Joined >= MaxPlayers {
+		return -1
+	}
+	g.Left[g.Joined] = false
+	g.Joined++
+	return g.Joined - 1
+}
+
+// Leave releases the slot of the specified player who left the game (e.g. its session was discarded).
+// The engine makes the Gopher of the player forfeit the race, so the race can finish without it.
+func (g *Game) Leave(player int) {
+	g.Left[player] = true
+	// Slots at the end are released (the first player is always kept), their flags are cleared when reused
+	for g.Joined > 1 && g.Left[g.Joined-1] {
+		g.Joined--
+	}
+}
+
+// Racing tells if there is a player still in the race.
+func (g *Game) Racing() bool {
+	for _, p := range g.Players {
+		if p.Racing() {
+			return true
+		}
+	}
+	return false
+}
+
+// Finished tells if at least one player reached the exit.
+func (g *Game) Finished() bool {
+	for _, p := range g.Players {
+		if p.Finished {
+			return true
+		}
+	}
+	return false
+}
+
+// initPlayers creates the players and positions their Gophers to the start position.
+func (g *Game) initPlayers() {
+	n := g.Joined
+	if g.Playback != nil {
+		// The players of a replay are the recorded ones
+		n = g.Playback.Players
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	start := BlockCenter(g.CurLevel.Start)
+	g.Players = make([]*Player, n)
+	for i := range g.Players {
+		p := &Player{
+			MovingObj:  &MovingObj{Direction: DirRight, TargetPos: start, Imgs: PlayerGopherImgs[i]},
+			DeadImg:    PlayerDeadImgs[i],
+			TargetPoss: make([]image.Point, 0, 20),
+		}
+		p.Pos.X = float64(start.X)
+		p.Pos.Y = float64(start.Y)
+		g.Players[i] = p
+	}
+}
+
+// nearestPlayer returns the index of the racing player nearest to the specified block,
+// -1 if no player is racing. Ties are broken in favor of the lower index.
+func (g *Game) nearestPlayer(block image.Point) int {
+	best, bestDist := -1, 0
+	for i, p := range g.Players {
+		if !p.Racing() {
+			continue
+		}
+		d := p.Block().Sub(block)
+		dist := abs(d.X) + abs(d.Y)
+		if best < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// RaceResults returns the lines of text to be displayed on the results screen of a race:
+// the finishing order and times of the players, followed by the players who did not finish.
+func (g *Game) RaceResults() []string {
+	order := make([]int, 0, len(g.Players))
+	for i, p := range g.Players {
+		if p.Finished {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return g.Players[order[a]].FinishTime < g.Players[order[b]].FinishTime
+	})
+
+	lines := []string{"Race results", ""}
+	for place, i := range order {
+		lines = append(lines, fmt.Sprintf("%d. %-6s %s", place+1, PlayerNames[i], FormatDuration(g.Players[i].FinishTime)))
+	}
+	for i, p := range g.Players {
+		if p.Forfeited {
+			lines = append(lines, fmt.Sprintf("   %-6s left", PlayerNames[i]))
+		} else if !p.Finished {
+			lines = append(lines, fmt.Sprintf("   %-6s caught", PlayerNames[i]))
+		}
+	}
+	return lines
+}
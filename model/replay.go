@@ -0,0 +1,274 @@
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Replay is the record of a game: the parameters and the configuration of the game,
+// and the inputs of the players stamped with the simulation tick they were processed at.
+// Since the simulation is deterministic, this is enough to reproduce the whole game.
+//
+// Replay files are gzip compressed JSON documents.
+type Replay struct {
+	// Game holds the parameters of the game
+	Game NewGame `json:"game"`
+
+	// Configuration of the game
+	Rows           int      `json:"rows"`
+	Cols           int      `json:"cols"`
+	BulldogDensity float64  `json:"bulldogs"`
+	V              float64  `json:"v"`
+	Braid          float64  `json:"braid"`
+	Behaviors      []string `json:"behaviors"`
+	LoopDelay      int      `json:"loopDelay"`
+	TickRate       int      `json:"tickRate"`
+	Players        int      `json:"players,omitempty"`
+
+	// Hunter tells if the game is a hunt, HuntTime is its time limit
+	Hunter   bool          `json:"hunter,omitempty"`
+	HuntTime time.Duration `json:"huntTime,omitempty"`
+
+	// Level is the fixed level in plain-text level format, empty if the labyrinth was generated
+	Level string `json:"level,omitempty"`
+
+	// Ticks is the length of the game in simulation ticks
+	Ticks int64 `json:"ticks"`
+
+	// Inputs of the players in the order they were processed
+	Inputs []Input `json:"inputs"`
+}
+
+// Limits of replays: the maximum length in ticks (2 hours at the highest tick rate), the maximum number of inputs,
+// and the maximum size of replay files in bytes (uncompressed, which is also a limit of the compressed size).
+const (
+	MaxReplayTicks  = 2 * 60 * 60 * 200
+	MaxReplayInputs = 100000
+	MaxReplaySize   = 16 << 20
+)
+
+// Input is an input of a player recorded in a Replay. Exactly one of Click, Key and Leave is set.
+type Input struct {
+	// Tick is the simulation tick the input was processed at
+	Tick int64 `json:"t"`
+
+	// Click is the mouse click input
+	Click *Click `json:"c,omitempty"`
+
+	// Key is the key event input
+	Key *Key `json:"k,omitempty"`
+
+	// Leave is the input of a player leaving the game
+	Leave *Leave `json:"l,omitempty"`
+}
+
+// Leave describes a player leaving the game: the Gopher of the player forfeits the race.
+type Leave struct {
+	// Player is the index of the player who left
+	Player int `json:"pl,omitempty"`
+}
+
+// ReplayCmd is a command controlling the playback of replays.
+type ReplayCmd struct {
+	// Replay to start playing back; if nil, this is a seek command
+	Replay *Replay
+
+	// Seek is the simulation tick to seek to in the replay being played back
+	Seek int64
+}
+
+// ReplayStatus describes the state of the replay mode.
+type ReplayStatus struct {
+	// Replaying tells if a replay is being played back
+	Replaying bool `json:"replaying"`
+
+	// Paused tells if the game (the playback) is paused
+	Paused bool `json:"paused"`
+
+	// Tick is the current simulation tick
+	Tick int64 `json:"tick"`
+
+	// Ticks is the length of the replay in ticks
+	Ticks int64 `json:"ticks"`
+
+	// TickRate is the tick rate of the replay
+	TickRate int `json:"tickRate"`
+}
+
+// ReplayStatus returns the state of the replay mode in the snapshot.
+func (s *Snapshot) ReplayStatus() ReplayStatus {
+	st := ReplayStatus{Replaying: s.Playback != nil, Paused: s.Paused, Tick: s.Tick}
+	if s.Playback != nil {
+		st.Ticks, st.TickRate = s.Playback.Ticks, s.Playback.TickRate
+	}
+	return st
+}
+
+// NewReplay returns a new, empty Replay of the current game.
+// Must be called right after InitNew(). LoopDelay and TickRate are to be filled by the caller.
+func (g *Game) NewReplay() *Replay {
+	rp := &Replay{
+		Game:           g.CurGame,
+		Rows:           g.Rows,
+		Cols:           g.Cols,
+		BulldogDensity: g.BulldogDensity,
+		V:              g.V,
+		Braid:          g.Braid,
+		Behaviors:      g.BulldogBehaviors,
+		Players:        len(g.Players),
+	}
+	if g.Hunting {
+		rp.Hunter, rp.HuntTime = true, g.HuntTime
+	}
+	if g.FixedLevel != nil {
+		buf := &bytes.Buffer{}
+		g.FixedLevel.Write(buf)
+		rp.Level = buf.String()
+	}
+	return rp
+}
+
+// LoadReplay loads a replay from the specified file.
+func LoadReplay(name string) (*Replay, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadReplay(f)
+}
+
+// ReadReplay reads and validates a replay from the specified reader.
+// Replays larger than MaxReplaySize (uncompressed) are rejected without reading them further.
+func ReadReplay(r io.Reader) (*Replay, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	// Read at most 1 byte more than allowed to detect larger replays
+	lr := &io.LimitedReader{R: zr, N: MaxReplaySize + 1}
+	rp := &Replay{}
+	err = json.NewDecoder(lr).Decode(rp)
+	if lr.N == 0 {
+		return nil, fmt.Errorf("replay exceeds %d bytes", MaxReplaySize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rp, rp.validate()
+}
+
+// validate validates the replay.
+// Returns nil if everything is ok, else an error.
+func (rp *Replay) validate() error {
+	if rp.Level == "" {
+		if rp.Rows < 9 || rp.Rows > 99 || rp.Cols < 9 || rp.Cols > 99 || rp.Rows&0x01 == 0 || rp.Cols&0x01 == 0 {
+			return fmt.Errorf("invalid size: %dx%d", rp.Rows, rp.Cols)
+		}
+		if GeneratorByName(rp.Game.Algorithm) == nil {
+			return fmt.Errorf("unknown algorithm: %s", rp.Game.Algorithm)
+		}
+	} else if _, err := ParseLevel(strings.NewReader(rp.Level)); err != nil {
+		return fmt.Errorf("invalid level: %v", err)
+	}
+	if rp.BulldogDensity < 0 || rp.BulldogDensity > 50 {
+		return fmt.Errorf("bulldogs %f is outside of valid range", rp.BulldogDensity)
+	}
+	if rp.V < 20 || rp.V > 200 {
+		return fmt.Errorf("v %f is outside of valid range", rp.V)
+	}
+	if rp.Braid < 0 || rp.Braid > 1 {
+		return fmt.Errorf("braid %f is outside of valid range", rp.Braid)
+	}
+	if len(rp.Behaviors) == 0 {
+		return errors.New("no behaviors")
+	}
+	for _, name := range rp.Behaviors {
+		if NewBehavior(name) == nil {
+			return fmt.Errorf("unknown behavior: %s", name)
+		}
+	}
+	if rp.LoopDelay < 10 || rp.LoopDelay > 100 {
+		return fmt.Errorf("loopDelay %d is outside of valid range", rp.LoopDelay)
+	}
+	if rp.TickRate < 10 || rp.TickRate > 200 {
+		return fmt.Errorf("tickRate %d is outside of valid range", rp.TickRate)
+	}
+	if rp.Players < 0 || rp.Players > MaxPlayers {
+		return fmt.Errorf("players %d is outside of valid range", rp.Players)
+	}
+	if rp.Hunter && (rp.HuntTime < 10*time.Second || rp.HuntTime > time.Hour) {
+		return fmt.Errorf("huntTime %v is outside of valid range", rp.HuntTime)
+	}
+	if rp.Ticks < 0 || rp.Ticks > MaxReplayTicks {
+		return fmt.Errorf("length %d is outside of valid range", rp.Ticks)
+	}
+	if len(rp.Inputs) > MaxReplayInputs {
+		return fmt.Errorf("too many inputs: %d", len(rp.Inputs))
+	}
+	for i, in := range rp.Inputs {
+		if in.Tick < 0 || in.Tick > rp.Ticks || i > 0 && in.Tick < rp.Inputs[i-1].Tick {
+			return fmt.Errorf("invalid tick of input #%d: %d", i+1, in.Tick)
+		}
+		n := 0
+		for _, set := range []bool{in.Click != nil, in.Key != nil, in.Leave != nil} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			return errors.New("input must have exactly one of a click, a key and a leave")
+		}
+		if in.Key != nil && (in.Key.Dir < 0 || in.Key.Dir >= DirLength) {
+			return fmt.Errorf("invalid key direction: %d", in.Key.Dir)
+		}
+		if in.Click != nil && (in.Click.Player < 0 || in.Click.Player >= MaxPlayers) ||
+			in.Key != nil && (in.Key.Player < 0 || in.Key.Player >= MaxPlayers) ||
+			in.Leave != nil && (in.Leave.Player < 0 || in.Leave.Player >= MaxPlayers) {
+			return errors.New("invalid player of input")
+		}
+	}
+	return nil
+}
+
+// Write writes the replay in replay file format to the specified writer.
+func (rp *Replay) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(rp); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ApplyReplay applies the configuration of the replay to the game so it's started with the recorded configuration.
+// The default configuration can be restored with ResetConfig().
+// Returns the parameters of the new game to start.
+func (g *Game) ApplyReplay(rp *Replay) NewGame {
+	g.Rows, g.Cols = rp.Rows, rp.Cols
+	g.BulldogDensity, g.V, g.Braid = rp.BulldogDensity, rp.V, rp.Braid
+	g.HuntTime = rp.HuntTime
+	g.BulldogBehaviors = rp.Behaviors
+	g.FixedLevel = nil
+	if rp.Level != "" {
+		// Replays are validated, level is valid
+		g.FixedLevel, _ = ParseLevel(strings.NewReader(rp.Level))
+		g.Rows, g.Cols = g.FixedLevel.Rows(), g.FixedLevel.Cols()
+	}
+	g.LabWidth, g.LabHeight = g.Cols*BlockSize, g.Rows*BlockSize
+
+	// The replay is a single game, not a campaign.
+	// The random number generator of campaign levels is seeded with the seed of the game plus the level index.
+	g.Campaign = nil
+	return NewGame{Algorithm: rp.Game.Algorithm, Seed: rp.Game.Seed + int64(rp.Game.Level)}
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+// validReplay returns a valid replay with the specified length and inputs.
+func validReplay(ticks int64, inputs []Input) *Replay {
+	return &Replay{
+		Game:           NewGame{Algorithm: Generators[0].Name(), Seed: 1},
+		Rows:           9,
+		Cols:           9,
+		BulldogDensity: 10,
+		V:              80,
+		Behaviors:      []string{"random"},
+		LoopDelay:      50,
+		TickRate:       100,
+		Ticks:          ticks,
+		Inputs:         inputs,
+	}
+}
+
+func TestReplayWithoutBehaviors(t *testing.T) {
+	rp := validReplay(100, nil)
+	rp.Behaviors = nil
+	if err := rp.validate(); err == nil {
+		t.Error("replay without behaviors accepted")
+	}
+}
+
+func TestReplayLimits(t *testing.T) {
+	key := func(tick int64) Input { return Input{Tick: tick, Key: &Key{Dir: DirRight}} }
+
+	if err := validReplay(100, []Input{key(0), key(50), key(100)}).validate(); err != nil {
+		t.Errorf("valid replay rejected: %v", err)
+	}
+	if err := validReplay(MaxReplayTicks, nil).validate(); err != nil {
+		t.Errorf("replay of max length rejected: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		rp   *Replay
+	}{
+		{"negative length", validReplay(-1, nil)},
+		{"too long", validReplay(MaxReplayTicks+1, nil)},
+		{"too many inputs", validReplay(MaxReplayTicks, make([]Input, MaxReplayInputs+1))},
+		{"input after the end", validReplay(100, []Input{key(101)})},
+		{"inputs out of order", validReplay(100, []Input{key(50), key(10)})},
+	}
+	for _, c := range cases {
+		if err := c.rp.validate(); err == nil {
+			t.Errorf("%s: replay accepted", c.name)
+		}
+	}
+}
+
+func TestReadOversizedReplay(t *testing.T) {
+	// A small compressed stream of a huge document
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"behaviors":["random"],"inputs":[`))
+	in := []byte(`{"t":0,"k":{"d":0}},`)
+	for n := 0; n < MaxReplaySize; n += len(in) {
+		zw.Write(in)
+	}
+	zw.Write([]byte(`{"t":0,"k":{"d":0}}]}`))
+	zw.Close()
+
+	if _, err := ReadReplay(&buf); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("got %v, want size error", err)
+	}
+}
+
+func TestReadReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rp := validReplay(100, []Input{{Tick: 10, Key: &Key{Dir: DirUp, Pressed: true}}})
+	if err := rp.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadReplay(&buf); err != nil {
+		t.Errorf("valid replay rejected: %v", err)
+	}
+}
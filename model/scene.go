@@ -0,0 +1,179 @@
+package model
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Scene is the static part of the view of a game for client-side rendering, sent once for each labyrinth.
+// The moving parts are sent in SceneFrames.
+type Scene struct {
+	// Lab is the labyrinth, one string for each row using the characters of the plain-text level format
+	// (LevelWall and LevelEmpty)
+	Lab []string `json:"lab"`
+
+	// Width and Height are the size of the labyrinth in pixels
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Exit is the position of the exit in pixel coordinates (the center of its block)
+	Exit image.Point `json:"exit"`
+
+	// Sprites tells where the images are in the sprite sheet (see SpriteSheet)
+	Sprites *SpriteLayout `json:"sprites"`
+}
+
+// SceneFrame is the moving part of the view of a game for client-side rendering, sent for each rendered frame.
+// Positions are in pixel coordinates, objects are to be drawn centered at their positions.
+type SceneFrame struct {
+	// Tick is the simulation tick of the frame
+	Tick int64 `json:"tick"`
+
+	// View is the rectangle of the labyrinth visible in the view of the client
+	// (clicks are interpreted relative to its top-left point)
+	View image.Rectangle `json:"view"`
+
+	// Gophers are the Gophers of the players (in the order of the players), Bulldogs are the Bulldogs
+	Gophers  []SceneObj `json:"gophers"`
+	Bulldogs []SceneObj `json:"bulldogs"`
+
+	// Targets are the positions of the target markers (the current and the queued targets of the racing Gophers)
+	Targets []image.Point `json:"targets"`
+
+	// HUD is the text of the head-up display (campaign status or the time left of the hunt), empty if there is none
+	HUD string `json:"hud,omitempty"`
+
+	// Lines are the lines of the text to display over the view (e.g. results), WonImg tells if the congratulation
+	// image is to be displayed instead
+	Lines  []string `json:"lines,omitempty"`
+	WonImg bool     `json:"wonImg,omitempty"`
+
+	// Paused tells if the game is paused
+	Paused bool `json:"paused,omitempty"`
+}
+
+// SceneObj is a moving object of a SceneFrame.
+type SceneObj struct {
+	// X, Y is the position of the object
+	X int `json:"x"`
+	Y int `json:"y"`
+
+	// Dir is the direction the object is facing toward
+	Dir Dir `json:"dir"`
+
+	// Dead tells if the Gopher died
+	Dead bool `json:"dead,omitempty"`
+}
+
+// SpriteLayout tells where the images of the game are in the sprite sheet.
+// Points are the top-left corners of images having the size of a block, rectangles are the bounds of other images.
+type SpriteLayout struct {
+	// BlockSize is the size of a block in pixels
+	BlockSize int `json:"blockSize"`
+
+	// Gophers are the Gopher images of the players for each direction, Dead are the dead Gopher images of the players
+	Gophers [][]image.Point `json:"gophers"`
+	Dead    []image.Point   `json:"dead"`
+
+	// Bulldogs are the Bulldog images for each direction
+	Bulldogs []image.Point `json:"bulldogs"`
+
+	// Wall is the image of the wall block, Exit is the image of the exit
+	Wall image.Point `json:"wall"`
+	Exit image.Point `json:"exit"`
+
+	// Target is the image of the target marker, Won is the congratulation image
+	Target image.Rectangle `json:"target"`
+	Won    image.Rectangle `json:"won"`
+}
+
+// SpriteSheet returns a sprite sheet containing all the images needed to render the game, and its layout.
+//
+// Each player has a row of Gopher images (one for each direction followed by the dead Gopher),
+// the next row holds the Bulldog images followed by the wall, the next one the exit and the target marker,
+// and the congratulation image is at the bottom.
+func SpriteSheet() (*image.RGBA, *SpriteLayout) {
+	l := &SpriteLayout{BlockSize: BlockSize}
+	cell := func(row, col int) image.Point {
+		return image.Pt(col*BlockSize, row*BlockSize)
+	}
+
+	for i := 0; i < MaxPlayers; i++ {
+		dirs := make([]image.Point, DirLength)
+		for d := range dirs {
+			dirs[d] = cell(i, d)
+		}
+		l.Gophers = append(l.Gophers, dirs)
+		l.Dead = append(l.Dead, cell(i, int(DirLength)))
+	}
+	for d := 0; d < int(DirLength); d++ {
+		l.Bulldogs = append(l.Bulldogs, cell(MaxPlayers, d))
+	}
+	l.Wall = cell(MaxPlayers, int(DirLength))
+	l.Exit = cell(MaxPlayers+1, 0)
+	l.Target = TargetImg.Bounds().Add(cell(MaxPlayers+1, 1))
+	l.Won = WonImg.Bounds().Add(cell(MaxPlayers+2, 0))
+
+	width := (int(DirLength) + 1) * BlockSize
+	if l.Won.Max.X > width {
+		width = l.Won.Max.X
+	}
+	sheet := image.NewRGBA(image.Rect(0, 0, width, l.Won.Max.Y))
+
+	put := func(img image.Image, p image.Point) {
+		draw.Draw(sheet, img.Bounds().Sub(img.Bounds().Min).Add(p), img, img.Bounds().Min, draw.Src)
+	}
+	for i := 0; i < MaxPlayers; i++ {
+		for d, p := range l.Gophers[i] {
+			put(PlayerGopherImgs[i][d], p)
+		}
+		put(PlayerDeadImgs[i], l.Dead[i])
+	}
+	for d, p := range l.Bulldogs {
+		put(BulldogImgs[d], p)
+	}
+	put(WallImg, l.Wall)
+	put(ExitImg, l.Exit)
+	put(TargetImg, l.Target.Min)
+	put(WonImg, l.Won.Min)
+
+	return sheet, l
+}
+
+// Scene returns the static part of the view of the game for client-side rendering. Sprites is to be filled by the caller.
+func (s *Snapshot) Scene() *Scene {
+	return &Scene{
+		Lab:    labLines(s.Lab),
+		Width:  s.LabWidth,
+		Height: s.LabHeight,
+		Exit:   s.ExitPos,
+	}
+}
+
+// SceneFrame returns the moving part of the view of the game for client-side rendering.
+// View and the overlays (HUD, Lines, WonImg) are to be filled by the caller.
+func (s *Snapshot) SceneFrame() *SceneFrame {
+	f := &SceneFrame{
+		Tick:     s.Tick,
+		Gophers:  make([]SceneObj, len(s.Players)),
+		Bulldogs: make([]SceneObj, len(s.Bulldogs)),
+		Paused:   s.Paused,
+	}
+	for i, p := range s.Players {
+		f.Gophers[i] = newSceneObj(p.MovingObj)
+		f.Gophers[i].Dead = p.Dead
+		if p.Racing() {
+			f.Targets = append(f.Targets, p.TargetPos)
+			f.Targets = append(f.Targets, p.TargetPoss...)
+		}
+	}
+	for i, bd := range s.Bulldogs {
+		f.Bulldogs[i] = newSceneObj(bd)
+	}
+	return f
+}
+
+// newSceneObj returns the scene object of the specified moving object.
+func newSceneObj(m *MovingObj) SceneObj {
+	return SceneObj{X: int(m.Pos.X), Y: int(m.Pos.Y), Dir: m.Direction}
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"image"
+	"image/draw"
+	"time"
+)
+
+// Snapshot is an immutable copy of the state of a game, published by the engine of the game at the end of
+// each iteration of its main loop (see Game.PublishSnapshot). The views and the APIs are created from snapshots,
+// so they never wait for the engine, and they never see a half-simulated tick.
+//
+// A snapshot must not be modified. The fields which are not modified by the engine after a game is initialized
+// (e.g. the labyrinth, the background image and the configuration) are shared with the game, everything else is copied.
+type Snapshot struct {
+	// Frame is the frame the snapshot was taken at (see Game.Frame), Tick is the simulation tick of the snapshot
+	Frame int64
+	Tick  int64
+
+	// CurGame holds the parameters of the game, CurLevel is its level
+	CurGame  NewGame
+	CurLevel *Level
+
+	// Lab is the model of the labyrinth, LabImg is the image of its static background (nil if the game is headless)
+	Lab    [][]Block
+	LabImg *image.RGBA
+
+	// LabWidth and LabHeight are the size of the labyrinth's image in pixels
+	LabWidth, LabHeight int
+
+	// Exit position in pixel coordinates
+	ExitPos image.Point
+
+	// Players are copies of the players
+	Players []*Player
+
+	// Bulldogs are copies of the moving objects of the Bulldogs
+	Bulldogs []*MovingObj
+
+	// Hunting tells if the game is a hunt (the first Bulldog is controlled by the hunter), HuntTime is its time limit
+	Hunting  bool
+	HuntTime time.Duration
+
+	// Won tells if the game is over, WonLines are the lines of the results (see Game.WonLines)
+	Won      bool
+	WonLines []string
+
+	// Paused tells if the game is paused
+	Paused bool
+
+	// Campaign is the progression table of the campaign (empty if campaign mode is disabled), and the campaign state
+	Campaign      []Stage
+	CampaignLevel int
+	LevelTime     time.Duration
+	CampaignTime  time.Duration
+
+	// Recording is a copy of the replay being recorded (up to Tick), nil while a replay is played back
+	Recording *Replay
+
+	// Playback is the replay being played back (it is not modified), nil if not in replay mode
+	Playback *Replay
+}
+
+// TakeSnapshot returns a new snapshot of the current state of the game.
+// The Mutex of the game must be locked when called (or it must be called by the engine of the game).
+func (g *Game) TakeSnapshot() *Snapshot {
+	s := &Snapshot{
+		Frame:         g.Frame,
+		Tick:          g.Tick,
+		CurGame:       g.CurGame,
+		CurLevel:      g.CurLevel,
+		Lab:           g.Lab,
+		LabImg:        g.LabImg,
+		LabWidth:      g.LabWidth,
+		LabHeight:     g.LabHeight,
+		ExitPos:       g.ExitPos,
+		Players:       make([]*Player, len(g.Players)),
+		Bulldogs:      make([]*MovingObj, len(g.Bulldogs)),
+		Hunting:       g.Hunting,
+		HuntTime:      g.HuntTime,
+		Won:           g.Won,
+		WonLines:      g.WonLines,
+		Paused:        g.Paused,
+		Campaign:      g.Campaign,
+		CampaignLevel: g.CampaignLevel,
+		LevelTime:     g.LevelTime,
+		CampaignTime:  g.CampaignTime,
+		Playback:      g.Playback,
+	}
+
+	for i, p := range g.Players {
+		p2 := *p
+		m := *p.MovingObj
+		p2.MovingObj = &m
+		p2.TargetPoss = append([]image.Point(nil), p.TargetPoss...)
+		s.Players[i] = &p2
+	}
+	for i, bd := range g.Bulldogs {
+		m := *bd.MovingObj
+		s.Bulldogs[i] = &m
+	}
+
+	if g.Recording != nil {
+		// Recorded inputs are only appended, so the copy may share them
+		rp := *g.Recording
+		rp.Ticks = g.Tick
+		s.Recording = &rp
+	}
+	return s
+}
+
+// PublishSnapshot takes a new snapshot of the game and publishes it, replacing the previous one (see Snapshot).
+// The Mutex of the game must be locked when called (or it must be called by the engine of the game).
+func (g *Game) PublishSnapshot() {
+	first := g.snapshot.Load() == nil
+	g.snapshot.Store(g.TakeSnapshot())
+	if first {
+		close(g.snapshotReady)
+	}
+}
+
+// Snapshot returns the latest snapshot published by the engine of the game.
+// If none has been published yet (the first game is being initialized), it waits for the first one.
+// It does not lock the Mutex of the game, it is safe for concurrent use.
+func (g *Game) Snapshot() *Snapshot {
+	if s, ok := g.snapshot.Load().(*Snapshot); ok {
+		return s
+	}
+	<-g.snapshotReady
+	return g.snapshot.Load().(*Snapshot)
+}
+
+// Compose returns a new image of the specified rectangle of the Labyrinth (in pixel coordinates):
+// the background (LabImg) with the target markers of the racing Gophers, the Gophers and the Bulldogs drawn onto it.
+// All objects are drawn, the drawing is clipped to the rectangle (by draw.Draw).
+func (s *Snapshot) Compose(r image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(r)
+	draw.Draw(img, r, s.LabImg, r.Min, draw.Src)
+
+	// Target markers
+	tr := TargetImg.Bounds()
+	drawTarget := func(p image.Point) {
+		draw.Draw(img, tr.Add(image.Pt(p.X-tr.Dx()/2, p.Y-tr.Dy()/2)), TargetImg, image.Point{}, draw.Over)
+	}
+	for _, p := range s.Players {
+		if !p.Racing() {
+			continue
+		}
+		drawTarget(p.TargetPos)
+		for _, t := range p.TargetPoss {
+			drawTarget(t)
+		}
+	}
+
+	// Moving objects
+	for _, p := range s.Players {
+		if p.Dead {
+			p.DrawWithImg(img, p.DeadImg)
+		} else {
+			p.DrawImg(img)
+		}
+	}
+	for _, bd := range s.Bulldogs {
+		bd.DrawImg(img)
+	}
+
+	return img
+}
+
+// HunterBulldog returns the Bulldog controlled by the hunter, nil if the game is not a hunt.
+func (s *Snapshot) HunterBulldog() *MovingObj {
+	if !s.Hunting || len(s.Bulldogs) == 0 {
+		return nil
+	}
+	return s.Bulldogs[0]
+}
@@ -0,0 +1,119 @@
+package model
+
+// Types of the requests sent by clients on the WebSocket of the API
+const (
+	// A mouse click in the view (SocketRequest.X, Y, Btn, PathFinding)
+	SocketClick = "click"
+	// A command of the API (SocketRequest.Command), also used for direction keys
+	SocketCommand = "command"
+	// Pauses or resumes the game (SocketRequest.Paused)
+	SocketPause = "pause"
+	// Starts a new game (SocketRequest.Game), replied with a SocketNewGame message
+	SocketNew = "new"
+	// Sets which updates the client wants to receive (SocketRequest.Frames, States, Scene, FPS, Quality)
+	SocketSubscribe = "subscribe"
+)
+
+// Types of the messages sent by the server on the WebSocket of the API
+const (
+	// The first message on each connection (SocketMessage.RunId)
+	SocketHello = "hello"
+	// A game event (SocketMessage.Event, You, Name)
+	SocketEvent = "event"
+	// The state of the game (SocketMessage.State), sent if the client subscribed to states
+	SocketState = "state"
+	// The replay status (SocketMessage.Replay), sent when it changes: on connecting, on entering and leaving
+	// replay mode, and as the playback advances
+	SocketReplay = "replay"
+	// The reply to a SocketNew request (SocketMessage.Game)
+	SocketNewGame = "newGame"
+	// The reply to an invalid or failed request (SocketMessage.Error)
+	SocketError = "error"
+	// The static part of the view for client-side rendering (SocketMessage.Scene), sent if the client subscribed
+	// to the scene, and again whenever the labyrinth changes
+	SocketScene = "scene"
+	// The moving part of the view for client-side rendering (SocketMessage.SceneFrame), sent if the client
+	// subscribed to the scene
+	SocketSceneFrame = "sceneFrame"
+	// A JPEG image of the view, sent as a binary message if the client subscribed to frames
+	// (SocketMessage.Frame, filled by clients)
+	SocketFrame = "frame"
+)
+
+// SocketRequest is a request of a client sent on the WebSocket of the API (in a text message, as JSON).
+// The fields used depend on the Type.
+type SocketRequest struct {
+	// Type is the type of the request
+	Type string `json:"type"`
+
+	// Id is an optional id of the request, sent back in the reply (SocketNewGame and SocketError messages)
+	Id int64 `json:"id,omitempty"`
+
+	// X, Y are the coordinates of a click in the view (in the coordinate system of the last frame sent),
+	// Btn is the mouse button, PathFinding tells if the Gopher is to find the way to the clicked block
+	X           int  `json:"x,omitempty"`
+	Y           int  `json:"y,omitempty"`
+	Btn         int  `json:"btn,omitempty"`
+	PathFinding bool `json:"pathFinding,omitempty"`
+
+	// Command is the command of a SocketCommand request
+	Command *Command `json:"command,omitempty"`
+
+	// Paused tells if the game is to be paused or resumed
+	Paused bool `json:"paused,omitempty"`
+
+	// Game holds the parameters of the new game (zero values mean defaults)
+	Game *NewGame `json:"game,omitempty"`
+
+	// Frames tells if the JPEG images of the view are to be sent, States tells if the states of the game are to be sent
+	Frames bool `json:"frames,omitempty"`
+	States bool `json:"states,omitempty"`
+
+	// Scene tells if the scene of the view is to be sent for client-side rendering: the labyrinth once
+	// (SocketScene), then only the moving objects (SocketSceneFrame)
+	Scene bool `json:"scene,omitempty"`
+
+	// FPS is the maximum number of frames, scene frames and states sent per second (1..60, 20 by default),
+	// Quality is the JPEG quality of the frames (0..100, 70 by default)
+	FPS     int  `json:"fps,omitempty"`
+	Quality *int `json:"quality,omitempty"`
+}
+
+// SocketMessage is a message sent by the server on the WebSocket of the API (in a text message, as JSON),
+// or a frame (in a binary message). The fields used depend on the Type.
+type SocketMessage struct {
+	// Type is the type of the message
+	Type string `json:"type"`
+
+	// Id is the id of the request the message replies to
+	Id int64 `json:"id,omitempty"`
+
+	// RunId is the running app id which changes if the app is restarted
+	RunId int64 `json:"runId,omitempty"`
+
+	// Event is the game event
+	Event *Event `json:"event,omitempty"`
+
+	// You tells if the event concerns the player of the client, Name is the name of the player concerned
+	You  bool   `json:"you,omitempty"`
+	Name string `json:"name,omitempty"`
+
+	// State is the state of the game
+	State *State `json:"state,omitempty"`
+
+	// Game holds the normalized parameters of the new game
+	Game *NewGame `json:"game,omitempty"`
+
+	// Replay is the replay status
+	Replay *ReplayStatus `json:"replay,omitempty"`
+
+	// Scene is the static part of the view, SceneFrame is the moving part of the view
+	Scene      *Scene      `json:"scene,omitempty"`
+	SceneFrame *SceneFrame `json:"sceneFrame,omitempty"`
+
+	// Error is the error message
+	Error string `json:"error,omitempty"`
+
+	// Frame is the JPEG image of the view
+	Frame []byte `json:"-"`
+}
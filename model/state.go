@@ -0,0 +1,156 @@
+package model
+
+import (
+	"image"
+)
+
+// APIVersion is the version of the JSON bot API.
+// It is to be incremented on incompatible changes of State and Command.
+const APIVersion = 1
+
+// State is a snapshot of the state of a game for bots, the document served by the JSON bot API.
+// Positions are in pixel coordinates (BlockSize pixels in a block), blocks are in block coordinates.
+type State struct {
+	// Version is the version of the API (APIVersion)
+	Version int `json:"version"`
+
+	// Game holds the parameters of the current game
+	Game NewGame `json:"game"`
+
+	// Tick is the number of simulation ticks since the game started, TickRate is the number of ticks per second
+	Tick     int64 `json:"tick"`
+	TickRate int   `json:"tickRate"`
+
+	// BlockSize is the size of a block in pixels
+	BlockSize int `json:"blockSize"`
+
+	// Lab is the labyrinth, one string for each row using the characters of the plain-text level format
+	// (LevelWall and LevelEmpty)
+	Lab []string `json:"lab"`
+
+	// Exit is the block of the exit
+	Exit image.Point `json:"exit"`
+
+	// Gophers are the Gophers of the players, Bulldogs are the Bulldogs
+	Gophers  []Actor `json:"gophers"`
+	Bulldogs []Actor `json:"bulldogs"`
+
+	// You is the index of the Gopher of the client, -1 if the client is the hunter
+	You int `json:"you"`
+
+	// Hunter is the index of the Bulldog of the hunter, -1 if the game is not a hunt
+	Hunter int `json:"hunter"`
+
+	// Dead tells if the Gopher of the client died, Won tells if the game is over
+	Dead bool `json:"dead"`
+	Won  bool `json:"won"`
+
+	// Paused tells if the game is paused
+	Paused bool `json:"paused"`
+}
+
+// Actor is the state of a moving object of the game.
+type Actor struct {
+	// X, Y is the position in pixel coordinates
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+
+	// Block is the block where the object is
+	Block image.Point `json:"block"`
+
+	// Dir is the name of the direction the object is facing toward
+	Dir string `json:"dir"`
+
+	// Target is the block the object is moving to
+	Target image.Point `json:"target"`
+
+	// Targets are the queued target blocks (only for Gophers)
+	Targets []image.Point `json:"targets,omitempty"`
+
+	// Dead and Finished tell if the Gopher died or reached the exit (only for Gophers)
+	Dead     bool `json:"dead,omitempty"`
+	Finished bool `json:"finished,omitempty"`
+}
+
+// Command is a command of a bot sent to the JSON bot API.
+// Either Waypoint (optionally with Clear) or Dir (direction key event) is to be specified.
+type Command struct {
+	// Waypoint is a block to move to along the shortest path, queued after the current targets
+	Waypoint *image.Point `json:"waypoint,omitempty"`
+
+	// Clear clears the queued targets (before queuing Waypoint)
+	Clear bool `json:"clear,omitempty"`
+
+	// Dir is the name of the direction of a key event, Pressed tells if the key is pressed (else released)
+	Dir     string `json:"dir,omitempty"`
+	Pressed bool   `json:"pressed,omitempty"`
+}
+
+// labLines returns the specified labyrinth, one string for each row using the characters
+// of the plain-text level format (LevelWall and LevelEmpty).
+func labLines(lab [][]Block) []string {
+	lines := make([]string, len(lab))
+	for ri, row := range lab {
+		line := make([]byte, len(row))
+		for ci, block := range row {
+			line[ci] = LevelEmpty
+			if block == BlockWall {
+				line[ci] = LevelWall
+			}
+		}
+		lines[ri] = string(line)
+	}
+	return lines
+}
+
+// newActor returns the state of the specified moving object.
+func newActor(m *MovingObj) Actor {
+	return Actor{
+		X:      m.Pos.X,
+		Y:      m.Pos.Y,
+		Block:  image.Pt(int(m.Pos.X)/BlockSize, int(m.Pos.Y)/BlockSize),
+		Dir:    m.Direction.String(),
+		Target: image.Pt(m.TargetPos.X/BlockSize, m.TargetPos.Y/BlockSize),
+	}
+}
+
+// State returns the state of the game as seen by the specified player
+// (or by the hunter if hunter is true). TickRate is to be filled by the caller.
+func (s *Snapshot) State(player int, hunter bool) *State {
+	st := &State{
+		Version:   APIVersion,
+		Game:      s.CurGame,
+		Tick:      s.Tick,
+		BlockSize: BlockSize,
+		Lab:       labLines(s.Lab),
+		Exit:      s.CurLevel.Exit,
+		Gophers:   make([]Actor, len(s.Players)),
+		Bulldogs:  make([]Actor, len(s.Bulldogs)),
+		You:       player,
+		Hunter:    -1,
+		Won:       s.Won,
+		Paused:    s.Paused,
+	}
+
+	for i, p := range s.Players {
+		a := newActor(p.MovingObj)
+		for _, t := range p.TargetPoss {
+			a.Targets = append(a.Targets, image.Pt(t.X/BlockSize, t.Y/BlockSize))
+		}
+		a.Dead, a.Finished = p.Dead, p.Finished
+		st.Gophers[i] = a
+	}
+	for i, bd := range s.Bulldogs {
+		st.Bulldogs[i] = newActor(bd)
+	}
+
+	if s.HunterBulldog() != nil {
+		st.Hunter = 0
+	}
+	if hunter {
+		st.You = -1
+	} else if player < len(s.Players) {
+		st.Dead = s.Players[player].Dead
+	}
+	return st
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gophergala/golab/env"
+	"github.com/gophergala/golab/model"
+	"github.com/gophergala/golab/tournament"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tournamentCmd is the name of the subcommand running a tournament of bots.
+const tournamentCmd = "tournament"
+
+// runTournament runs the tournament subcommand with the specified arguments:
+// processes its flags, runs the tournament and prints / exports the scoreboard.
+// Returns nil if everything is ok, else an error (flag.ErrHelp if invalid flags were reported with the usage).
+func runTournament(args []string) error {
+	fs := flag.NewFlagSet("golab "+tournamentCmd, flag.ExitOnError)
+
+	bots := fs.String("bots", strings.Join(tournament.BotNames(), ","), "comma separated list of the bots to play; valid values: "+strings.Join(tournament.BotNames(), ", "))
+	configs := fs.String("configs", "21x21:6:70,33x33:10:80", "comma separated list of the labyrinth configurations to play, each in ROWSxCOLS:BULLDOGS:V format")
+	algorithms := fs.String("algorithms", model.Algorithm, "comma separated list of the labyrinth generator algorithms, each configuration is played with each of them; valid values: "+strings.Join(model.GeneratorNames(), ", "))
+	behaviors := fs.String("behaviors", strings.Join(model.BulldogBehaviors, ","), "comma separated list of the behaviors of the Bulldogs, assigned to them in turns; valid values: "+strings.Join(model.BehaviorNames(), ", "))
+	braid := fs.Float64("braid", 0, "the fraction of dead ends to remove from generated labyrinths (creating loops); valid range: 0..1")
+	seeds := fs.String("seeds", "1-20", "comma separated list of the seeds (or ranges of seeds like 1-20) of the games; 0 is not allowed")
+	tickRate := fs.Int("tickRate", env.DefaultConfig.TickRate, "number of simulation ticks per second of the games; valid range: 10..200")
+	ticksPerStep := fs.Int("ticksPerStep", env.DefaultConfig.TicksPerStep, "number of simulation ticks between the decisions of the bots; valid range: 1..100")
+	timeLimit := fs.Duration("timeLimit", tournament.DefaultTimeLimit, "game time after which a game is stopped (a timeout); valid range: 10s..1h")
+	parallel := fs.Int("parallel", 0, "number of games to run in parallel; 0 means the number of CPUs")
+	csvFile := fs.String("csv", "", "the file to export the scoreboard to in CSV format; empty means no CSV export")
+	jsonFile := fs.String("json", "", "the file to export the scoreboard and the results of the games to in JSON format; empty means no JSON export")
+
+	fs.Parse(args)
+
+	usageErr := func(err error) error {
+		fmt.Println(err)
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	botNames := strings.Split(*bots, ",")
+	for i, name := range botNames {
+		botNames[i] = strings.TrimSpace(name)
+		if tournament.NewBot(botNames[i], 1) == nil {
+			return usageErr(fmt.Errorf("unknown bot: %q", name))
+		}
+	}
+
+	stages, err := model.ParseCampaign(*configs)
+	if err != nil {
+		return usageErr(fmt.Errorf("invalid configs: %v", err))
+	}
+	behaviorNames, err := model.ParseBehaviors(*behaviors)
+	if err != nil {
+		return usageErr(err)
+	}
+	if *ticksPerStep < 1 || *ticksPerStep > 100 {
+		return usageErr(fmt.Errorf("ticksPerStep %d is outside of valid range", *ticksPerStep))
+	}
+	if *timeLimit < 10*time.Second || *timeLimit > time.Hour {
+		return usageErr(fmt.Errorf("timeLimit %v is outside of valid range", *timeLimit))
+	}
+
+	var envConfigs []env.Config
+	for _, alg := range strings.Split(*algorithms, ",") {
+		for _, st := range stages {
+			c := env.DefaultConfig
+			c.Rows, c.Cols, c.BulldogDensity, c.V = st.Rows, st.Cols, st.BulldogDensity, st.V
+			c.Braid, c.Algorithm, c.Behaviors = *braid, strings.TrimSpace(alg), behaviorNames
+			c.TickRate, c.TicksPerStep = *tickRate, *ticksPerStep
+			c.MaxSteps = tournament.MaxSteps(c, *timeLimit)
+			// Validate the configuration by creating an environment with it
+			if _, err := env.New(c); err != nil {
+				return usageErr(fmt.Errorf("invalid config %s: %v", tournament.ConfigName(c), err))
+			}
+			envConfigs = append(envConfigs, c)
+		}
+	}
+
+	seedList, err := parseSeeds(*seeds)
+	if err != nil {
+		return usageErr(err)
+	}
+
+	fmt.Printf("Playing %d games: %d bots, %d configs, %d seeds...\n",
+		len(botNames)*len(envConfigs)*len(seedList), len(botNames), len(envConfigs), len(seedList))
+	start := time.Now()
+	results := tournament.Run(botNames, envConfigs, seedList, *parallel)
+	fmt.Printf("Done in %v.\n\n", time.Since(start))
+
+	for _, r := range results {
+		if r.Err != "" {
+			fmt.Printf("Game of %s in %s with seed %d failed: %s\n", r.Bot, r.Config, r.Seed, r.Err)
+		}
+	}
+
+	board := tournament.Scoreboard(results)
+	if err := tournament.WriteTable(os.Stdout, board); err != nil {
+		return err
+	}
+
+	if *csvFile != "" {
+		if err := exportFile(*csvFile, func(f *os.File) error { return tournament.WriteCSV(f, board) }); err != nil {
+			return err
+		}
+	}
+	if *jsonFile != "" {
+		if err := exportFile(*jsonFile, func(f *os.File) error { return tournament.WriteJSON(f, board, results) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSeeds parses a comma separated list of seeds and ranges of seeds (e.g. "1-20,42").
+func parseSeeds(s string) ([]int64, error) {
+	var seeds []int64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		from, to := part, part
+		// A leading '-' is the sign of a negative seed, not a range separator
+		if i := strings.Index(part, "-"); i == 0 && len(part) > 1 {
+			if i = strings.Index(part[1:], "-"); i >= 0 {
+				from, to = part[:i+1], part[i+2:]
+			}
+		} else if i > 0 {
+			from, to = part[:i], part[i+1:]
+		}
+		first, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seeds %q: %v", part, err)
+		}
+		last, err := strconv.ParseInt(to, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seeds %q: %v", part, err)
+		}
+		if last < first || last-first >= 100000 {
+			return nil, fmt.Errorf("invalid seed range: %q", part)
+		}
+		for seed := first; seed <= last; seed++ {
+			if seed == 0 {
+				return nil, fmt.Errorf("seed 0 is not allowed (it means a random seed)")
+			}
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds, nil
+}
+
+// exportFile creates the file having the specified name, and writes it with the specified function.
+func exportFile(name string, write func(f *os.File) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Println("Exported", name)
+	return nil
+}
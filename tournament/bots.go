@@ -0,0 +1,174 @@
+package tournament
+
+import (
+	"github.com/gophergala/golab/env"
+	"github.com/gophergala/golab/model"
+	"image"
+	"math/rand"
+)
+
+// Bot is a strategy controlling Gopher in a tournament.
+// A new instance is created for each game, so a Bot may keep state between the steps of its game.
+type Bot interface {
+	// Name returns the name of the Bot which identifies it in the tournament and in the scoreboard
+	Name() string
+
+	// Act returns the action to perform in the observed state of the game
+	Act(obs *model.State) env.Action
+}
+
+// Bots is the list of the registered Bots, in the form of functions creating new instances
+// for a game having the specified seed.
+// Custom strategies can be registered by appending to it before running a tournament.
+var Bots = []func(seed int64) Bot{
+	func(seed int64) Bot { return greedyBot{} },
+	func(seed int64) Bot { return wallFollowerBot{} },
+	func(seed int64) Bot { return &randomBot{r: rand.New(rand.NewSource(seed))} },
+}
+
+// NewBot returns a new instance of the registered Bot having the specified name for a game having the specified seed,
+// nil if there is no such Bot.
+func NewBot(name string, seed int64) Bot {
+	for _, f := range Bots {
+		if b := f(seed); b.Name() == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// BotNames returns the names of the registered Bots.
+func BotNames() []string {
+	names := make([]string, len(Bots))
+	for i, f := range Bots {
+		names[i] = f(0).Name()
+	}
+	return names
+}
+
+// actionOf returns the action which moves Gopher in the specified direction.
+func actionOf(dir model.Dir) env.Action {
+	return env.ActionRight + env.Action(dir)
+}
+
+// isFree tells if the specified block of the observed labyrinth is inside of it and is free.
+func isFree(obs *model.State, p image.Point) bool {
+	return p.Y >= 0 && p.Y < len(obs.Lab) && p.X >= 0 && p.X < len(obs.Lab[p.Y]) && obs.Lab[p.Y][p.X] == model.LevelEmpty
+}
+
+// lab returns the blocks of the observed labyrinth.
+func lab(obs *model.State) [][]model.Block {
+	l := make([][]model.Block, len(obs.Lab))
+	for ri, row := range obs.Lab {
+		l[ri] = make([]model.Block, len(row))
+		for ci := range row {
+			if row[ci] != model.LevelEmpty {
+				l[ri][ci] = model.BlockWall
+			}
+		}
+	}
+	return l
+}
+
+// greedyBot walks to the exit along the shortest path, ignoring the Bulldogs.
+type greedyBot struct{}
+
+func (greedyBot) Name() string {
+	return "greedy"
+}
+
+func (greedyBot) Act(obs *model.State) env.Action {
+	// Gopher turns at the block it is moving to, so the path is planned from there
+	me := obs.Gophers[obs.You]
+	path := model.ShortestPath(lab(obs), me.Target, obs.Exit)
+	if len(path) < 2 {
+		return env.ActionStop
+	}
+	delta := path[1].Sub(path[0])
+	for d := model.Dir(0); d < model.DirLength; d++ {
+		if d.Delta() == delta {
+			return actionOf(d)
+		}
+	}
+	return env.ActionStop
+}
+
+// wallFollowerBot walks with its right hand on the wall, which leads to the exit in labyrinths without loops.
+type wallFollowerBot struct{}
+
+func (wallFollowerBot) Name() string {
+	return "wallfollower"
+}
+
+func (wallFollowerBot) Act(obs *model.State) env.Action {
+	me := obs.Gophers[obs.You]
+	dir, _ := model.DirByName(me.Dir)
+
+	// Prefer turning right, then going straight, then turning left
+	right := dir
+	for d := model.Dir(0); d < model.DirLength; d++ {
+		if delta := dir.Delta(); d.Delta() == image.Pt(-delta.Y, delta.X) {
+			right = d
+		}
+	}
+	for _, d := range []model.Dir{right, dir, right.Opposite()} {
+		if isFree(obs, me.Target.Add(d.Delta())) {
+			return actionOf(d)
+		}
+	}
+	return deadEnd(me, dir)
+}
+
+// deadEnd returns the action to perform if Gopher is moving to a dead end in the specified direction:
+// Gopher turns back only when arrived, else it would turn back right away, skipping the blocks in between.
+func deadEnd(me model.Actor, dir model.Dir) env.Action {
+	if arrived(me) {
+		return actionOf(dir.Opposite())
+	}
+	return actionOf(dir)
+}
+
+// arrived tells if the Gopher of the specified state arrived at its target.
+func arrived(me model.Actor) bool {
+	pos := model.BlockCenter(me.Target)
+	return int(me.X) == pos.X && int(me.Y) == pos.Y
+}
+
+// randomBot walks randomly: it chooses a random direction at each junction, avoiding turning back.
+type randomBot struct {
+	// r is the random number generator of the Bot, seeded with the seed of the game
+	r *rand.Rand
+
+	// target and dir are the block Gopher was moving to and its direction when the Bot last chose a direction
+	target image.Point
+	dir    model.Dir
+
+	// action is the last chosen action, ActionStop if none yet
+	action env.Action
+}
+
+func (*randomBot) Name() string {
+	return "random"
+}
+
+func (b *randomBot) Act(obs *model.State) env.Action {
+	me := obs.Gophers[obs.You]
+	dir, _ := model.DirByName(me.Dir)
+	if b.action != env.ActionStop && me.Target == b.target && dir == b.dir {
+		return b.action
+	}
+
+	var dirs []model.Dir
+	for d := model.Dir(0); d < model.DirLength; d++ {
+		if d != dir.Opposite() && isFree(obs, me.Target.Add(d.Delta())) {
+			dirs = append(dirs, d)
+		}
+	}
+	if len(dirs) == 0 {
+		// Not recorded as chosen: the Bot decides again when Gopher arrived
+		return deadEnd(me, dir)
+	}
+
+	b.target, b.dir, b.action = me.Target, dir, actionOf(dirs[b.r.Intn(len(dirs))])
+	return b.action
+}
@@ -0,0 +1,241 @@
+/*
+Package tournament runs tournaments of bots: every bot plays every labyrinth configuration with every seed,
+headlessly and as fast as possible (using the environment of the env package), and the results are summarized
+in a scoreboard.
+
+Bots are strategies registered in Bots (see Bot). Since games are deterministic, a tournament
+with the same bots, configurations and seeds always produces the same results.
+Games of configurations without a step limit (MaxSteps of env.Config) are stopped after DefaultTimeLimit.
+
+A simple tournament:
+
+	results := tournament.Run(tournament.BotNames(), []env.Config{env.DefaultConfig}, []int64{1, 2, 3}, 0)
+	tournament.WriteTable(os.Stdout, tournament.Scoreboard(results))
+*/
+package tournament
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/gophergala/golab/env"
+	"github.com/gophergala/golab/model"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultTimeLimit is the game time after which games of configurations without a step limit are stopped.
+const DefaultTimeLimit = 5 * time.Minute
+
+// Result is the result of a game of a tournament.
+type Result struct {
+	// Bot is the name of the bot, Config is the name of the labyrinth configuration (see ConfigName())
+	Bot    string `json:"bot"`
+	Config string `json:"config"`
+
+	// Seed is the seed of the game
+	Seed int64 `json:"seed"`
+
+	// Won tells if Gopher reached the exit, Dead tells if Gopher died;
+	// if neither, the time limit of the game was reached
+	Won  bool `json:"won"`
+	Dead bool `json:"dead"`
+
+	// Time is the game time in seconds when the game ended
+	Time float64 `json:"time"`
+
+	// Err is the error which prevented playing the game, if any
+	Err string `json:"err,omitempty"`
+}
+
+// Score is a row of the scoreboard: the summarized results of a bot in a labyrinth configuration
+// (or in all configurations if Config is AllConfigs).
+type Score struct {
+	Bot    string `json:"bot"`
+	Config string `json:"config"`
+
+	// Games is the number of played games, Wins, Deaths and Timeouts are the numbers of their outcomes
+	Games    int `json:"games"`
+	Wins     int `json:"wins"`
+	Deaths   int `json:"deaths"`
+	Timeouts int `json:"timeouts"`
+
+	// WinRate is the fraction of the won games
+	WinRate float64 `json:"winRate"`
+
+	// MeanTime is the mean time to exit of the won games in seconds, 0 if no game was won
+	MeanTime float64 `json:"meanTime"`
+}
+
+// AllConfigs is the Config of the rows of the scoreboard summarizing all configurations.
+const AllConfigs = "all"
+
+// ConfigName returns the name of the specified configuration used in results:
+// the labyrinth parameters in the format of the campaign stages and the generator algorithm.
+func ConfigName(c env.Config) string {
+	st := model.Stage{Rows: c.Rows, Cols: c.Cols, BulldogDensity: c.BulldogDensity, V: c.V}
+	return st.String() + "/" + c.Algorithm
+}
+
+// MaxSteps returns the number of steps of a game of the specified configuration which take the specified game time.
+func MaxSteps(c env.Config, limit time.Duration) int {
+	return int(limit.Seconds() * float64(c.TickRate) / float64(c.TicksPerStep))
+}
+
+// Run runs a tournament: every bot plays every configuration with every seed.
+// Games are run on the specified number of goroutines in parallel (0 means the number of CPUs).
+// Returns the results in the order of configurations, seeds and bots.
+func Run(bots []string, configs []env.Config, seeds []int64, parallel int) []Result {
+	results := make([]Result, 0, len(configs)*len(seeds)*len(bots))
+	for _, c := range configs {
+		for _, seed := range seeds {
+			for _, bot := range bots {
+				results = append(results, Result{Bot: bot, Config: ConfigName(c), Seed: seed})
+			}
+		}
+	}
+
+	if parallel <= 0 {
+		parallel = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				c := configs[j/(len(seeds)*len(bots))]
+				play(&results[j], c)
+			}
+		}()
+	}
+	for j := range results {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// play plays the game of the specified result, and fills the outcome.
+// If the configuration has no step limit, the game is stopped after DefaultTimeLimit.
+func play(r *Result, c env.Config) {
+	if c.MaxSteps <= 0 {
+		c.MaxSteps = MaxSteps(c, DefaultTimeLimit)
+	}
+	b := NewBot(r.Bot, r.Seed)
+	if b == nil {
+		r.Err = "unknown bot: " + r.Bot
+		return
+	}
+	e, err := env.New(c)
+	if err != nil {
+		r.Err = err.Error()
+		return
+	}
+
+	obs := e.Reset(r.Seed)
+	for done := false; !done; {
+		obs, _, done = e.Step(b.Act(obs))
+	}
+
+	r.Won, r.Dead = obs.Gophers[obs.You].Finished, obs.Dead
+	r.Time = float64(obs.Tick) / float64(obs.TickRate)
+}
+
+// Scoreboard summarizes the specified results: returns a row for each bot and configuration,
+// and if there are multiple configurations, a row for each bot summarizing all of them (after the other rows).
+// Rows are in the order of first appearance in the results. Results having an error are skipped.
+func Scoreboard(results []Result) []Score {
+	var scores []*Score
+	byKey := map[[2]string]*Score{}
+	configs := map[string]bool{}
+
+	score := func(bot, config string) *Score {
+		key := [2]string{bot, config}
+		s := byKey[key]
+		if s == nil {
+			s = &Score{Bot: bot, Config: config}
+			byKey[key] = s
+			scores = append(scores, s)
+		}
+		return s
+	}
+
+	for _, r := range results {
+		if r.Err != "" {
+			continue
+		}
+		configs[r.Config] = true
+		for _, s := range []*Score{score(r.Bot, r.Config), score(r.Bot, AllConfigs)} {
+			s.Games++
+			switch {
+			case r.Won:
+				s.Wins++
+				s.MeanTime += r.Time // Sum for now
+			case r.Dead:
+				s.Deaths++
+			default:
+				s.Timeouts++
+			}
+		}
+	}
+
+	board := make([]Score, 0, len(scores))
+	for _, all := range []bool{false, true} {
+		for _, s := range scores {
+			if (s.Config == AllConfigs) != all || all && len(configs) < 2 {
+				continue
+			}
+			s.WinRate = float64(s.Wins) / float64(s.Games)
+			if s.Wins > 0 {
+				s.MeanTime /= float64(s.Wins)
+			}
+			board = append(board, *s)
+		}
+	}
+	return board
+}
+
+// WriteTable writes the scoreboard as a human readable, aligned table.
+func WriteTable(w io.Writer, board []Score) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Bot\tConfig\tGames\tWins\tWin rate\tMean time\tDeaths\tTimeouts\t")
+	for _, s := range board {
+		meanTime := "-"
+		if s.Wins > 0 {
+			meanTime = fmt.Sprintf("%.1fs", s.MeanTime)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f%%\t%s\t%d\t%d\t\n",
+			s.Bot, s.Config, s.Games, s.Wins, s.WinRate*100, meanTime, s.Deaths, s.Timeouts)
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes the scoreboard in CSV format, with a header row.
+func WriteCSV(w io.Writer, board []Score) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"bot", "config", "games", "wins", "winRate", "meanTime", "deaths", "timeouts"})
+	for _, s := range board {
+		cw.Write([]string{s.Bot, s.Config, strconv.Itoa(s.Games), strconv.Itoa(s.Wins),
+			strconv.FormatFloat(s.WinRate, 'f', 4, 64), strconv.FormatFloat(s.MeanTime, 'f', 2, 64),
+			strconv.Itoa(s.Deaths), strconv.Itoa(s.Timeouts)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the scoreboard and the results of the individual games as a JSON document.
+func WriteJSON(w io.Writer, board []Score, results []Result) error {
+	enc := json.NewEncoder(w)
+	return enc.Encode(struct {
+		Scoreboard []Score  `json:"scoreboard"`
+		Results    []Result `json:"results"`
+	}{board, results})
+}
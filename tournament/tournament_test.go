@@ -0,0 +1,123 @@
+package tournament
+
+import (
+	"github.com/gophergala/golab/env"
+	"github.com/gophergala/golab/model"
+	"reflect"
+	"testing"
+)
+
+// testConfig returns a small configuration for test games.
+func testConfig(rows, cols int, bulldogDensity float64) env.Config {
+	c := env.DefaultConfig
+	c.Rows, c.Cols, c.BulldogDensity = rows, cols, bulldogDensity
+	c.Algorithm, c.Behaviors = "backtracker", []string{"random"}
+	c.MaxSteps = 200
+	return c
+}
+
+func TestScoreboard(t *testing.T) {
+	cases := []struct {
+		name    string
+		results []Result
+		board   []Score
+	}{
+		{
+			name: "single config",
+			results: []Result{
+				{Bot: "a", Config: "c1", Seed: 1, Won: true, Time: 10},
+				{Bot: "b", Config: "c1", Seed: 1, Dead: true, Time: 3},
+				{Bot: "a", Config: "c1", Seed: 2, Won: true, Time: 20},
+				{Bot: "b", Config: "c1", Seed: 2, Time: 60},
+				{Bot: "a", Config: "c1", Seed: 3, Dead: true, Time: 5},
+			},
+			board: []Score{
+				{Bot: "a", Config: "c1", Games: 3, Wins: 2, Deaths: 1, WinRate: 2.0 / 3, MeanTime: 15},
+				{Bot: "b", Config: "c1", Games: 2, Deaths: 1, Timeouts: 1},
+			},
+		},
+		{
+			name: "multiple configs",
+			results: []Result{
+				{Bot: "a", Config: "c1", Seed: 1, Won: true, Time: 10},
+				{Bot: "a", Config: "c2", Seed: 1, Won: true, Time: 30},
+				{Bot: "a", Config: "c2", Seed: 2, Dead: true, Time: 4},
+			},
+			board: []Score{
+				{Bot: "a", Config: "c1", Games: 1, Wins: 1, WinRate: 1, MeanTime: 10},
+				{Bot: "a", Config: "c2", Games: 2, Wins: 1, Deaths: 1, WinRate: 0.5, MeanTime: 30},
+				{Bot: "a", Config: AllConfigs, Games: 3, Wins: 2, Deaths: 1, WinRate: 2.0 / 3, MeanTime: 20},
+			},
+		},
+		{
+			name: "errors are skipped",
+			results: []Result{
+				{Bot: "a", Config: "c1", Seed: 1, Won: true, Time: 10},
+				{Bot: "a", Config: "c2", Seed: 1, Err: "invalid config"},
+			},
+			board: []Score{
+				{Bot: "a", Config: "c1", Games: 1, Wins: 1, WinRate: 1, MeanTime: 10},
+			},
+		},
+		{
+			name:  "no results",
+			board: []Score{},
+		},
+	}
+	for _, c := range cases {
+		if board := Scoreboard(c.results); !reflect.DeepEqual(board, c.board) {
+			t.Errorf("%s: got %+v, want %+v", c.name, board, c.board)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	bots := []string{"greedy", "random"}
+	configs := []env.Config{testConfig(9, 9, 0), testConfig(15, 15, 20)}
+	seeds := []int64{3, -1}
+
+	results := Run(bots, configs, seeds, 4)
+	if n := len(configs) * len(seeds) * len(bots); len(results) != n {
+		t.Fatalf("got %d results, want %d", len(results), n)
+	}
+	i := 0
+	for _, c := range configs {
+		for _, seed := range seeds {
+			for _, bot := range bots {
+				if r := results[i]; r.Config != ConfigName(c) || r.Seed != seed || r.Bot != bot || r.Err != "" {
+					t.Errorf("result #%d: got %+v, want %s %d %s", i, r, ConfigName(c), seed, bot)
+				}
+				i++
+			}
+		}
+	}
+
+	// The games of the configurations are not mixed up: the greedy bot always wins without Bulldogs
+	for _, r := range results[:len(seeds)*len(bots)] {
+		if r.Bot == "greedy" && !r.Won {
+			t.Errorf("greedy did not win without Bulldogs: %+v", r)
+		}
+	}
+
+	if results2 := Run(bots, configs, seeds, 1); !reflect.DeepEqual(results, results2) {
+		t.Errorf("results differ:\n%+v\n%+v", results, results2)
+	}
+}
+
+// stallingBot is a bot which never moves.
+type stallingBot struct{}
+
+func (stallingBot) Name() string                    { return "stalling" }
+func (stallingBot) Act(obs *model.State) env.Action { return env.ActionStop }
+
+func TestRunWithoutStepLimit(t *testing.T) {
+	Bots = append(Bots, func(seed int64) Bot { return stallingBot{} })
+	defer func() { Bots = Bots[:len(Bots)-1] }()
+
+	c := testConfig(9, 9, 0)
+	c.MaxSteps = 0
+	r := Run([]string{"stalling"}, []env.Config{c}, []int64{1}, 1)[0]
+	if r.Won || r.Dead || r.Err != "" || r.Time != DefaultTimeLimit.Seconds() {
+		t.Errorf("got %+v, want a timeout after %v", r, DefaultTimeLimit)
+	}
+}
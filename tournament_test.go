@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSeeds(t *testing.T) {
+	cases := []struct {
+		s     string
+		seeds []int64
+	}{
+		{"1", []int64{1}},
+		{"1-3,42", []int64{1, 2, 3, 42}},
+		{" 7 , 8-9 ", []int64{7, 8, 9}},
+		{"-5", []int64{-5}},
+		{"-5--3", []int64{-5, -4, -3}},
+		{"-2-2", nil}, // Contains 0
+		{"-1-1", nil},
+		{"0", nil},
+		{"1-", nil},
+		{"-", nil},
+		{"", nil},
+		{"3-1", nil},
+		{"1-100001", nil}, // Too many
+		{"x", nil},
+		{"1-x", nil},
+	}
+	for _, c := range cases {
+		seeds, err := parseSeeds(c.s)
+		if c.seeds == nil {
+			if err == nil {
+				t.Errorf("%q: got %v, want error", c.s, seeds)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(seeds, c.seeds) {
+			t.Errorf("%q: got %v, %v, want %v", c.s, seeds, err, c.seeds)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package view
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gophergala/golab/ctrl"
+	"github.com/gophergala/golab/model"
+	"net/http"
+)
+
+// Path prefix of the JSON bot API, it contains the API version.
+const apiPath = "/api/v1/"
+
+// errBusy is the error sent if the input queue of the game is full (or a new game got ahead of the requested one).
+var errBusy = errors.New("Too many commands, retry later")
+
+// apiStateHandle serves the state of the game of the client as a model.State JSON document.
+func apiStateHandle(w http.ResponseWriter, r *http.Request) {
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.state(s.game.Snapshot()))
+}
+
+// state returns the state of the game of the session in the specified snapshot of the game.
+func (s *session) state(snap *model.Snapshot) *model.State {
+	state := snap.State(s.player, s.hunter)
+	state.TickRate = ctrl.TickRate
+	if snap.Playback != nil {
+		state.TickRate = snap.Playback.TickRate
+	}
+	return state
+}
+
+// apiCommandHandle receives a model.Command JSON document (in the body of a POST request),
+// and forwards it to the engine the same way as the inputs of the UI web page.
+func apiCommandHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST a command", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var cmd model.Command
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		http.Error(w, "Invalid command: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	if err := s.command(cmd); err != nil {
+		replyError(w, err)
+	}
+}
+
+// replyError replies the specified error of a request: 503 (Service Unavailable) if the game is busy (errBusy)
+// or too many games are running (errTooManyGames), 400 (Bad Request) otherwise.
+func replyError(w http.ResponseWriter, err error) {
+	if err == errBusy || err == errTooManyGames {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// command forwards the specified command of the session to the engine.
+// errBusy is returned if the input queue of the game is full.
+func (s *session) command(cmd model.Command) error {
+	g := s.game
+
+	if cmd.Dir != "" {
+		dir, ok := model.DirByName(cmd.Dir)
+		if !ok {
+			return errors.New("Invalid direction: " + cmd.Dir)
+		}
+		if s.hunter {
+			return errors.New("The Bulldog of the hunter is controlled by waypoints only")
+		}
+		// Use non-blocking send
+		select {
+		case g.KeyCh <- model.Key{Dir: dir, Pressed: cmd.Pressed, Player: s.player}:
+			return nil
+		default:
+			return errBusy
+		}
+	}
+
+	if cmd.Waypoint == nil && !cmd.Clear {
+		return errors.New("Either a waypoint or a direction is required")
+	}
+
+	// Waypoints are sent as path finding clicks at the center of the block, clearing is done by the right button
+	c := model.Click{PathFinding: true, Player: s.player, Hunter: s.hunter}
+	if cmd.Clear {
+		c.Btn = model.MouseBtnRight
+	}
+	if cmd.Waypoint != nil {
+		c.X, c.Y = cmd.Waypoint.X*model.BlockSize+model.BlockSize/2, cmd.Waypoint.Y*model.BlockSize+model.BlockSize/2
+	} else {
+		// Only clear: click the current target, nothing is queued after clearing
+		snap := g.Snapshot()
+		if bd := snap.HunterBulldog(); s.hunter && bd != nil {
+			c.X, c.Y = bd.TargetPos.X, bd.TargetPos.Y
+		} else {
+			p := s.playerOf(snap)
+			c.X, c.Y = p.TargetPos.X, p.TargetPos.Y
+		}
+	}
+	// Use non-blocking send
+	select {
+	case g.ClickCh <- c:
+		return nil
+	default:
+		return errBusy
+	}
+}
+
+// apiNewGameHandle starts a new game. The parameters of the new game are received as a model.NewGame JSON document
+// (in the body of a POST request), the normalized parameters are sent back.
+func apiNewGameHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST the parameters of the new game", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ng model.NewGame
+	if err := json.NewDecoder(r.Body).Decode(&ng); err != nil {
+		http.Error(w, "Invalid parameters: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s, err := newSession(w, r)
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+	ng, err = startNewGame(s.game, ng)
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ng)
+}
@@ -9,3 +9,8 @@ var (
 	// Width of the client view in pixels
 	ViewHeight int
 )
+
+// PathFinding is the default of the path finding setting of the clients.
+// If enabled, any reachable block can be clicked, Gopher will move there along the shortest path.
+// Else only blocks in the same row or column can be clicked with a free straight passage to them.
+var PathFinding bool
@@ -0,0 +1,109 @@
+package view
+
+import (
+	"encoding/json"
+	"github.com/gophergala/golab/model"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// LogEvents tells if the events of the games are to be logged.
+var LogEvents bool
+
+// ServerStats are the statistics of all the games of the server, collected from the events of the games.
+// Events of replays being played back are not counted.
+type ServerStats struct {
+	// Games is the number of started games (including the levels of campaigns)
+	Games int64 `json:"games"`
+
+	// Wins is the number of Gophers reached the exit, Deaths is the number of Gophers caught by Bulldogs
+	Wins   int64 `json:"wins"`
+	Deaths int64 `json:"deaths"`
+
+	// Waypoints is the number of accepted waypoints, RejectedWaypoints is the number of rejected waypoints
+	Waypoints         int64 `json:"waypoints"`
+	RejectedWaypoints int64 `json:"rejectedWaypoints"`
+
+	// Spotted is the number of times a Bulldog spotted a Gopher
+	Spotted int64 `json:"spotted"`
+}
+
+var (
+	// stats are the statistics of the games of the server
+	stats ServerStats
+
+	// Mutex to be used to synchronize access to stats
+	statsMutex sync.Mutex
+)
+
+// watchGame subscribes to the events of the specified game, and collects statistics from them
+// (and logs them if LogEvents is true) until the engine of the game stops.
+// Must be called before the first game is started so no event is missed.
+func watchGame(gameId string, g *model.Game) {
+	sub := g.Events.Subscribe(100)
+	go func() {
+		for ev := range sub.C {
+			if LogEvents {
+				logEvent(gameId, ev)
+			}
+			if !ev.Replay {
+				countEvent(ev)
+			}
+		}
+	}()
+}
+
+// logEvent logs the specified event of the game having the specified id.
+func logEvent(gameId string, ev model.Event) {
+	who := model.PlayerNames[ev.Player]
+	if ev.Hunter {
+		who = "hunter"
+	}
+	replay := ""
+	if ev.Replay {
+		replay = " (replay)"
+	}
+
+	switch ev.Type {
+	case model.EventGameStarted:
+		log.Printf("Game %s tick %d%s: %s, algorithm: %s, seed: %d, level: %d",
+			gameId, ev.Tick, replay, ev.Type, ev.Game.Algorithm, ev.Game.Seed, ev.Game.Level)
+	case model.EventBulldogSpotted, model.EventGopherDied:
+		log.Printf("Game %s tick %d%s: %s, player: %s, bulldog: %d, block: %v",
+			gameId, ev.Tick, replay, ev.Type, who, ev.Bulldog, ev.Block)
+	default:
+		log.Printf("Game %s tick %d%s: %s, player: %s, block: %v", gameId, ev.Tick, replay, ev.Type, who, ev.Block)
+	}
+}
+
+// countEvent updates the statistics of the games with the specified event.
+func countEvent(ev model.Event) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	switch ev.Type {
+	case model.EventGameStarted:
+		stats.Games++
+	case model.EventGopherWon:
+		stats.Wins++
+	case model.EventGopherDied:
+		stats.Deaths++
+	case model.EventWaypointAccepted:
+		stats.Waypoints++
+	case model.EventWaypointRejected:
+		stats.RejectedWaypoints++
+	case model.EventBulldogSpotted:
+		stats.Spotted++
+	}
+}
+
+// apiStatsHandle serves the statistics of all the games of the server as a ServerStats JSON document.
+func apiStatsHandle(w http.ResponseWriter, r *http.Request) {
+	statsMutex.Lock()
+	st := stats
+	statsMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
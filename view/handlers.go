@@ -1,22 +1,47 @@
 package view
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gophergala/golab/model"
 	"html/template"
 	"image"
+	"image/color"
+	"image/draw"
 	"image/jpeg"
 	"net/http"
 	"strconv"
 	"time"
 )
 
-var Params = struct {
+// Params holds the parameters of the html templates.
+type Params struct {
 	Title         string
-	Width, Height *int
+	Width, Height int
 	RunId         int64
-	ShowFreezeBtn bool
-}{AppTitle, &ViewWidth, &ViewHeight, time.Now().Unix(), false}
+	Paused        bool
+	Algorithms    []string
+	CurGame       model.NewGame
+	PathFinding   bool
+	GameId        string
+	Player        string
+}
+
+// runId is the running app id which changes if app is restarted.
+var runId = time.Now().Unix()
+
+// newParams returns the template parameters for the specified session.
+func newParams(s *session) *Params {
+	snap := s.game.Snapshot() // Params refers to the current game
+	player := model.PlayerNames[s.player]
+	if s.hunter {
+		player = "Bulldog"
+	}
+	return &Params{AppTitle, ViewWidth, ViewHeight, runId, snap.Paused, model.GeneratorNames(), snap.CurGame, PathFinding,
+		s.gameId, player}
+}
 
 // Template of the play html page
 var playTempl = template.Must(template.New("t").Parse(play_html))
@@ -24,46 +49,95 @@ var playTempl = template.Must(template.New("t").Parse(play_html))
 // Template of the help html page
 var helpTempl = template.Must(template.New("t").Parse(help_html))
 
-// The client's (browser's) view position inside the Labyrinth image. This is the top-left point of the view.
-var Pos image.Point
-
 // init registers the http handlers.
 func init() {
 	http.HandleFunc("/", playHtmlHandle)
 	http.HandleFunc("/runid", runIdHandle)
+	http.HandleFunc("/events", eventsHandle)
 	http.HandleFunc("/img", imgHandle)
+	http.HandleFunc("/stream", streamHandle)
+	http.HandleFunc("/sprites", spritesHandle)
 	http.HandleFunc("/clicked", clickedHandle)
+	http.HandleFunc("/key", keyHandle)
+	http.HandleFunc("/pause", pauseHandle)
 	http.HandleFunc("/cheat", cheatHandle)
 	http.HandleFunc("/new", newGameHandle)
 	http.HandleFunc("/help", helpHtmlHandle)
-}
-
-// InitNew initializes a new game.
-func InitNew() {
-	Pos = image.Point{}
+	http.HandleFunc("/export", exportHandle)
+	http.HandleFunc("/stats", statsHandle)
+	http.HandleFunc("/record", recordHandle)
+	http.HandleFunc("/replay", replayHandle)
+	http.HandleFunc("/join", joinHandle)
+	http.HandleFunc(apiPath+"state", apiStateHandle)
+	http.HandleFunc(apiPath+"command", apiCommandHandle)
+	http.HandleFunc(apiPath+"new", apiNewGameHandle)
+	http.HandleFunc(apiPath+"stats", apiStatsHandle)
+	http.HandleFunc(apiPath+"socket", socketHandle)
 }
 
 // playHtmlHandle serves the html page where the user can play.
 func playHtmlHandle(w http.ResponseWriter, r *http.Request) {
-	playTempl.Execute(w, Params)
+	s, err := newSession(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	playTempl.Execute(w, newParams(s))
 }
 
 // runidHandle serves the running app id which changes if app is restarted
 // (so browser clients can detect if app was restarted).
 func runIdHandle(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "%d", Params.RunId)
+	fmt.Fprintf(w, "%d", runId)
 }
 
 // imgHandle serves images of the player's view.
 func imgHandle(w http.ResponseWriter, r *http.Request) {
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(s.viewJPEG(s.game.Snapshot(), parseQuality(r)))
+}
+
+// parseQuality returns the JPEG quality specified by the "quality" parameter of the request, 70 by default.
+func parseQuality(r *http.Request) int {
 	quality, err := strconv.Atoi(r.FormValue("quality"))
 	if err != nil || quality < 0 || quality > 100 {
 		quality = 70
 	}
+	return quality
+}
+
+// viewJPEG returns the view of the session in the specified snapshot of its game
+// encoded as a JPEG image with the specified quality.
+// The position of the view is stored in the session.
+func (s *session) viewJPEG(snap *model.Snapshot, quality int) []byte {
+	view := snap.Compose(s.viewRect(snap))
+	if len(snap.Campaign) > 0 || snap.Hunting {
+		drawHUD(snap, view)
+	}
+	if p := s.playerOf(snap); snap.Won {
+		drawWon(snap, view)
+	} else if !s.hunter && p.Finished {
+		drawFinished(p, view)
+	}
+	if snap.Paused {
+		drawPaused(view)
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, view, &jpeg.Options{quality})
+
+	return buf.Bytes()
+}
 
-	// Center Gopher in view if possible
-	gpos := model.Gopher.Pos
-	rect := image.Rect(0, 0, ViewWidth, ViewHeight).Add(image.Pt(int(gpos.X)-ViewWidth/2, int(gpos.Y)-ViewHeight/2))
+// viewRect returns the rectangle of the Labyrinth visible in the view of the session
+// in the specified snapshot of its game, and stores the new view's position in the session.
+func (s *session) viewRect(snap *model.Snapshot) image.Rectangle {
+	// Center the Gopher (or the Bulldog of the hunter) of the player in view if possible
+	c := s.center(snap)
+	rect := image.Rect(0, 0, ViewWidth, ViewHeight).Add(image.Pt(c.X-ViewWidth/2, c.Y-ViewHeight/2))
 
 	// But needs correction at the edges of the view (it can't be centered)
 	corr := image.Point{}
@@ -73,22 +147,83 @@ func imgHandle(w http.ResponseWriter, r *http.Request) {
 	if rect.Min.Y < 0 {
 		corr.Y = -rect.Min.Y
 	}
-	if rect.Max.X > model.LabWidth {
-		corr.X = model.LabWidth - rect.Max.X
+	if rect.Max.X > snap.LabWidth {
+		corr.X = snap.LabWidth - rect.Max.X
 	}
-	if rect.Max.Y > model.LabHeight {
-		corr.Y = model.LabHeight - rect.Max.Y
+	if rect.Max.Y > snap.LabHeight {
+		corr.Y = snap.LabHeight - rect.Max.Y
 	}
-	rect = rect.Add(corr)
-
-	model.Mutex.Lock()
-	jpeg.Encode(w, model.LabImg.SubImage(rect), &jpeg.Options{quality})
-	model.Mutex.Unlock()
+	// The Labyrinth of the game might be smaller than the view (e.g. when playing back a replay)
+	rect = rect.Add(corr).Intersect(image.Rect(0, 0, snap.LabWidth, snap.LabHeight))
 
 	// Store the new view's position:
-	Pos = rect.Min
+	s.mutex.Lock()
+	s.pos = rect.Min
+	s.mutex.Unlock()
+
+	return rect
+}
+
+// hudText returns the text of the HUD: the campaign status (level and time) or the remaining time of the hunt.
+func hudText(snap *model.Snapshot) string {
+	if snap.Hunting {
+		left := snap.HuntTime - snap.LevelTime
+		if left < 0 {
+			left = 0
+		}
+		return "Time left: " + model.FormatDuration(left)
+	}
+	return fmt.Sprintf("Level %d/%d  %s", snap.CampaignLevel+1, len(snap.Campaign),
+		model.FormatDuration(snap.CampaignTime+snap.LevelTime))
 }
 
+// drawHUD draws the campaign status (level and time) or the remaining time of the hunt onto the view image.
+func drawHUD(snap *model.Snapshot, view *image.RGBA) {
+	text := hudText(snap)
+	const scale = 2
+	r := image.Rectangle{Max: model.TextSize(text, scale)}.Add(view.Rect.Min).Add(image.Pt(2*scale, 2*scale))
+	draw.Draw(view, r.Inset(-scale), hudImg, image.Point{}, draw.Over)
+	model.DrawText(view, text, r.Min, scale, color.White)
+}
+
+// drawWon draws the winning screen onto the view image.
+// In campaign mode the level complete screen is drawn, else the congratulation image.
+func drawWon(snap *model.Snapshot, view *image.RGBA) {
+	r := view.Rect
+	center := r.Min.Add(r.Size().Div(2))
+
+	if len(snap.WonLines) > 0 {
+		model.DrawTextCentered(view, snap.WonLines, center, model.FitScale(snap.WonLines, r.Dx(), 3), color.White)
+		return
+	}
+
+	wr := model.WonImg.Bounds()
+	wr = wr.Add(center.Sub(image.Pt(wr.Dx()/2, wr.Dy()/2)))
+	draw.Draw(view, wr, model.WonImg, image.Point{}, draw.Over)
+}
+
+// drawFinished draws the status of the player who finished the race while others are still racing.
+func drawFinished(p *model.Player, view *image.RGBA) {
+	lines := finishedLines(p)
+	r := view.Rect
+	model.DrawTextCentered(view, lines, r.Min.Add(r.Size().Div(2)), model.FitScale(lines, r.Dx(), 3), color.White)
+}
+
+// finishedLines returns the lines of the status of the player who finished the race while others are still racing.
+func finishedLines(p *model.Player) []string {
+	return []string{"You finished!", model.FormatDuration(p.FinishTime), "", "Waiting for the others..."}
+}
+
+// drawPaused draws the paused overlay onto the view image.
+func drawPaused(view *image.RGBA) {
+	lines := []string{"Paused"}
+	r := view.Rect
+	model.DrawTextCentered(view, lines, r.Min.Add(r.Size().Div(2)), model.FitScale(lines, r.Dx(), 6), color.White)
+}
+
+// hudImg is the image of the background of the HUD.
+var hudImg = image.NewUniform(color.RGBA{A: 0x80})
+
 // clickedHandle receives mouse click (mouse button pressed) events with mouse coordinates.
 func clickedHandle(w http.ResponseWriter, r *http.Request) {
 	x, err := strconv.Atoi(r.FormValue("x"))
@@ -103,32 +238,243 @@ func clickedHandle(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
+	if s := getSession(w, r); s != nil {
+		s.click(x, y, btn, r.FormValue("p") == "1")
+	}
+}
+
+// click sends a mouse click of the session to the engine.
+// x, y are in the coordinate system of the client's view.
+func (s *session) click(x, y, btn int, pathFinding bool) {
+	s.mutex.Lock()
+	pos := s.pos
+	s.mutex.Unlock()
+
+	// Translate x, y to the Labyrinth's coordinate system:
+	select {
+	case s.game.ClickCh <- model.Click{X: pos.X + x, Y: pos.Y + y, Btn: btn, PathFinding: pathFinding, Player: s.player, Hunter: s.hunter}:
+	default:
+	}
+}
+
+// keyHandle receives key events of the direction keys.
+// The "d" parameter is the name of the direction, "s" is the state of the key: 1 if pressed, 0 if released.
+func keyHandle(w http.ResponseWriter, r *http.Request) {
+	dir, ok := model.DirByName(r.FormValue("d"))
+	if !ok {
+		return
+	}
+
+	s := getSession(w, r)
+	if s == nil || s.hunter {
+		return // The Bulldog of the hunter is controlled by mouse clicks only
+	}
+	select {
+	case s.game.KeyCh <- model.Key{Dir: dir, Pressed: r.FormValue("s") == "1", Player: s.player}:
+	default:
+	}
+}
+
+// pauseHandle pauses (if the "p" parameter is 1) or resumes (if 0) the game.
+func pauseHandle(w http.ResponseWriter, r *http.Request) {
+	if s := getSession(w, r); s != nil {
+		pauseGame(s.game, r.FormValue("p") == "1")
+	}
+}
 
-	// x, y are in the coordinate system of the client's view.
-	// Translate them to the Labyrinth's coordinate system:
+// pauseGame pauses (if paused is true) or resumes the specified game.
+func pauseGame(g *model.Game, paused bool) {
 	select {
-	case model.ClickCh <- model.Click{Pos.X + x, Pos.Y + y, btn}:
+	case g.PauseCh <- paused:
 	default:
 	}
 }
 
 // cheatHandle serves the whole image of the Labyrinth.
+// If the "solution" parameter is provided, the shortest path from the start to the exit is drawn onto it.
 func cheatHandle(w http.ResponseWriter, r *http.Request) {
-	model.Mutex.Lock()
-	jpeg.Encode(w, model.LabImg, &jpeg.Options{70})
-	model.Mutex.Unlock()
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	snap := s.game.Snapshot()
+
+	img := snap.Compose(snap.LabImg.Bounds())
+	if r.FormValue("solution") == "" {
+		jpeg.Encode(w, img, &jpeg.Options{70})
+		return
+	}
+
+	// Mark the center of the blocks of the path
+	const size = model.BlockSize / 4
+	for _, p := range snap.CurLevel.Analyze().Path {
+		x, y := p.X*model.BlockSize+model.BlockSize/2, p.Y*model.BlockSize+model.BlockSize/2
+		draw.Draw(img, image.Rect(x-size/2, y-size/2, x+size/2, y+size/2), solutionImg, image.Point{}, draw.Over)
+	}
+
+	jpeg.Encode(w, img, &jpeg.Options{70})
+}
+
+// solutionImg is the image used to mark the solution path.
+var solutionImg = image.NewUniform(color.RGBA{G: 0xc0, A: 0xc0})
+
+// statsHandle serves the Stats of the level of the current game in JSON format.
+func statsHandle(w http.ResponseWriter, r *http.Request) {
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	stats := s.game.Snapshot().CurLevel.Analyze()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
 }
 
 // newGameHandle signals to start a newgame.
+// The labyrinth generator algorithm can be specified with the "algorithm" parameter,
+// the seed of the game with the "seed" parameter.
+// The parameters of the new game are sent back in JSON format.
 func newGameHandle(w http.ResponseWriter, r *http.Request) {
-	// Use non-blocking send
+	ng := model.NewGame{Algorithm: r.FormValue("algorithm")}
+	if s := r.FormValue("seed"); s != "" {
+		var err error
+		if ng.Seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "Invalid seed: "+s, http.StatusBadRequest)
+			return
+		}
+	}
+	s, err := newSession(w, r)
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+	ng, err = startNewGame(s.game, ng)
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ng)
+}
+
+// startNewGame validates and normalizes the specified parameters of a new game, and starts it in the specified game.
+// The normalized parameters are returned so the client can be told the seed of the new game.
+// errBusy is returned if the new game could not be sent to the engine (another new game got ahead of it).
+// The level of the campaign cannot be chosen by clients: new games start the campaign over.
+func startNewGame(g *model.Game, ng model.NewGame) (model.NewGame, error) {
+	if ng.Algorithm != "" && model.GeneratorByName(ng.Algorithm) == nil {
+		return ng, errors.New("Unknown algorithm: " + ng.Algorithm)
+	}
+	ng.Level = 0 // Only the engine advances the level, when the previous one is won
+	ng.Normalize(len(model.Campaign))
+	if !sendNewGame(g, ng) {
+		return ng, errBusy
+	}
+	return ng, nil
+}
+
+// sendNewGame sends the new game parameters to the engine of the specified game.
+// A new game not yet started by the engine is replaced (e.g. the first game of a new session).
+// Returns false if the new game was dropped because another one was sent concurrently.
+func sendNewGame(g *model.Game, ng model.NewGame) bool {
+	// Use non-blocking operations
+	select {
+	case <-g.NewGameCh:
+	default:
+	}
 	select {
-	case model.NewGameCh <- 1:
+	case g.NewGameCh <- ng:
+		return true
 	default:
+		return false
 	}
 }
 
+// exportHandle serves the level of the current game in plain-text level format as a downloadable file.
+func exportHandle(w http.ResponseWriter, r *http.Request) {
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	snap := s.game.Snapshot()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="golab-%s-%d.txt"`, snap.CurGame.Algorithm, snap.CurGame.Seed))
+	snap.CurLevel.Write(w)
+}
+
+// recordHandle serves the recording of the current game (up to the current tick) as a downloadable replay file.
+func recordHandle(w http.ResponseWriter, r *http.Request) {
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	rp := s.game.Snapshot().Recording
+	if rp == nil {
+		http.Error(w, "The current game is not recorded (a replay is being played back).", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="golab-%s-%d.replay"`, rp.Game.Algorithm, rp.Game.Seed))
+	rp.Write(w)
+}
+
+// replayHandle controls the playback of replays.
+// A replay file posted in the request body is started to play back.
+// The "seek" parameter seeks to the specified tick in the replay being played back.
+// Pausing and resuming the playback is done the same way as pausing and resuming the game (pauseHandle),
+// starting a new game ends the replay mode.
+// The model.ReplayStatus is sent back in JSON format.
+func replayHandle(w http.ResponseWriter, r *http.Request) {
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	g := s.game
+
+	var cmd *model.ReplayCmd
+	if r.Method == "POST" {
+		// Compressed replays are smaller than the uncompressed size limit
+		rp, err := model.ReadReplay(http.MaxBytesReader(w, r.Body, model.MaxReplaySize))
+		if err != nil {
+			http.Error(w, "Invalid replay: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		cmd = &model.ReplayCmd{Replay: rp}
+	} else if s := r.FormValue("seek"); s != "" {
+		t, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid tick: "+s, http.StatusBadRequest)
+			return
+		}
+		cmd = &model.ReplayCmd{Seek: t}
+	}
+
+	if cmd != nil {
+		// Use non-blocking send
+		select {
+		case g.ReplayCh <- *cmd:
+		default:
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g.Snapshot().ReplayStatus())
+}
+
 // helpHtmlHandle serves the help html page.
 func helpHtmlHandle(w http.ResponseWriter, r *http.Request) {
-	helpTempl.Execute(w, Params)
+	helpTempl.Execute(w, &Params{Title: AppTitle})
+}
+
+// joinHandle makes the client join the game specified by the "g" parameter as a new player
+// (as the hunter if the "as" parameter is "hunter"), and redirects to the play page.
+func joinHandle(w http.ResponseWriter, r *http.Request) {
+	if err := joinGame(w, r, r.FormValue("g"), r.FormValue("as") == "hunter"); err != nil {
+		http.Error(w, "Failed to join: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
@@ -27,6 +27,28 @@ const help_html = `<html>
 		(but there must be a free straight line to it). You can even queue multiple target points forming a <i>path</i>
 		on which Gopher will move along. If you click with the <i>right</i> mouse button, the path will be cleared.
 	</p>
+	<p>
+		If <i>Path finding</i> is checked, you can click anywhere in the Labyrinth: Gopher will find the shortest way there.
+	</p>
+	<p>
+		You can also control Gopher with the <i>arrow keys</i> or with <i>W</i>, <i>A</i>, <i>S</i>, <i>D</i>:
+		Gopher moves as long as you hold a key. If you press a new direction ahead of time,
+		Gopher turns at the next junction where it's possible. Releasing the keys stops Gopher at the next block.
+	</p>
+	<p>
+		The game can be paused with the <i>Pause</i> button; it is also paused automatically when you switch to another tab.
+	</p>
+	<p>
+		You can race with your friends in the same Labyrinth: send them the link given by the <i>Invite</i> link.
+		Each player controls their own Gopher, the first to reach the Exit point wins.
+		A friend can also hunt you as a Bulldog: send them the link given by the <i>Invite hunter</i> link.
+		The hunter has to catch you within the time limit.
+	</p>
+	<p>
+		Every game is recorded: the replay of the current game can be downloaded with the <i>Record</i> link.
+		A replay file can be played back with the <i>Replay...</i> link: it can be paused and resumed with the <i>Pause</i> button,
+		and you can seek in it with the slider. Starting a new game exits the replay mode.
+	</p>
 </div>
 
 <div id="close">
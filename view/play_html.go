@@ -10,7 +10,12 @@ const play_html = `<html>
 	#controls      {padding: 2px;}
 	#controls *    {margin-left: 3px; margin-right: 3px;}
 	#view          {position: relative; padding: 1px;}
-	#img           {background: #000; border: 1px solid black;}
+	#img, #canvas  {background: #000; border: 1px solid black;}
+	#canvas        {display: none;}
+	#replayBar     {display: none; padding: 2px;}
+	#replayBar *   {margin-left: 3px; margin-right: 3px; vertical-align: middle;}
+	#seekBar       {width: 300px;}
+	#status        {min-height: 1.2em; padding: 2px; font-weight: bold;}
 	#errMsg        {visibility: hidden; position: absolute; top: 10px; right: 0px; width: 100%; color: #ff3030; font-weight: bold;}
 	#footer        {margin-top: 5px; font-size: 90%; font-style: italic;}
 </style>
@@ -55,23 +60,61 @@ const play_html = `<html>
 		<option value="1000">1</option>
 	</select>
 	
-	<button id="pauseResume" onclick="pauseResume()" {{if not .ShowFreezeBtn}}style="display: none"{{end}} 
-		title="Stops refreshing the view but does not pauses the game">Freeze</button>
+	<label title="If checked, the view is drawn by the browser from the positions of the objects (sharp and needs less bandwidth). Else the view is received as JPEG images.">
+		<input type="checkbox" id="canvasMode" checked>Canvas</label>
 	
+	<label title="If checked, you can click anywhere, Gopher will find the way. Else only straight paths can be clicked.">
+		<input type="checkbox" id="pathFinding" {{if .PathFinding}}checked{{end}}>Path finding</label>
+	
+	<button id="pauseResume" onclick="pauseResume(!paused)" title="Pauses or resumes the game">Pause</button>
+	
+	<select id="algorithm" title="Labyrinth generator algorithm of the new game">
+		{{range .Algorithms}}<option value="{{.}}">{{.}}</option>
+		{{end}}
+	</select>
 	<button id="newGame" onclick="newGame()">New Game</button>
 	
+	<a id="seed" href="#" title="Seed of the current game. Share this link to play the same game.">Seed: ?</a>
+	
+	<a href="/join?g={{.GameId}}" onclick="prompt('Share this link with the other players to race with them:', this.href); return false;"
+		title="Invite other players to race in the same Labyrinth. You are the {{.Player}} player.">Invite ({{.Player}})</a>
+	
+	<a href="/join?g={{.GameId}}&amp;as=hunter" onclick="prompt('Share this link with the player who hunts the Gophers as a Bulldog:', this.href); return false;"
+		title="Invite a player to hunt the Gophers as a Bulldog">Invite hunter</a>
+	
 	<a href="/help" target="_blank">Help</a>
 	
 	<a href="/cheat" target="_blank" title="Get a glimpse of the whole Labyrinth">Cheat</a>
 	
+	<a href="/cheat?solution=1" target="_blank" title="Show the shortest path to the Exit">Solution</a>
+	
+	<a href="/stats" target="_blank" title="Difficulty metrics of the Labyrinth">Stats</a>
+	
+	<a href="/export" title="Download the Labyrinth in plain-text level format">Export</a>
+	
+	<a href="/record" title="Download the replay of the current game">Record</a>
+	
+	<a href="#" onclick="replayFile.click(); return false;" title="Play back a replay file">Replay...</a>
+	<input type="file" id="replayFile" style="display: none">
+	
 	<a href="https://github.com/gophergala/golab" target="_blank" title="Visit Home Page">Home page</a>
 </div>
 
+<div id="replayBar">
+	Replay:
+	<input type="range" id="seekBar" min="0" max="0" value="0" title="Seek in the replay">
+	<span id="replayTime"></span>
+	<button onclick="newGame()" title="Exit replay mode and start a new game">Exit Replay</button>
+</div>
+
+<div id="status"></div>
+
 <div id="view">
 	<img id="img" width="{{.Width}}" height="{{.Height}}"
 		onload="errMsg.style.visibility = 'hidden'; imgLoaded = true;"
-		onerror="errMsg.style.visibility = 'visible'; setTimeout('imgLoaded = true;', 1000);"
+		onerror="imgError()"
 		onmousedown="imgClicked(event)"/>
+	<canvas id="canvas" width="{{.Width}}" height="{{.Height}}" onmousedown="imgClicked(event)"></canvas>
 	<div id="errMsg">Connection Error or Application Closed!</div>
 </div>
 
@@ -81,32 +124,324 @@ const play_html = `<html>
 
 <script>
 	var runId = {{.RunId}};
-	var playing = false, imgLoaded = true;
+	var paused = false, imgLoaded = true;
+	var streaming = false, streamId = 0;
+	var socket = null, frameUrl = null; // The connected WebSocket, and the object URL of the last frame received on it
 	
 	// HTML elements:
 	var img            = document.getElementById("img"),
 		errMsg         = document.getElementById("errMsg"),
 		quality        = document.getElementById("quality"),
 		fps            = document.getElementById("fps"),
-		pauseResumeBtn = document.getElementById("pauseResume");
+		algorithm      = document.getElementById("algorithm"),
+		seedLink       = document.getElementById("seed"),
+		pathFinding    = document.getElementById("pathFinding"),
+		pauseResumeBtn = document.getElementById("pauseResume"),
+		replayFile     = document.getElementById("replayFile"),
+		replayBar      = document.getElementById("replayBar"),
+		seekBar        = document.getElementById("seekBar"),
+		replayTime     = document.getElementById("replayTime"),
+		statusDiv      = document.getElementById("status"),
+		canvas         = document.getElementById("canvas"),
+		canvasMode     = document.getElementById("canvasMode");
+	
+	showGame({{.CurGame}});
 	
 	// Disable image dragging and right-click context menu:
-	img.oncontextmenu = img.ondragstart = function() { return false; }
+	img.oncontextmenu = img.ondragstart = canvas.oncontextmenu = function() { return false; }
+	
+	// Client-side rendering: the sprite sheet, the scene (labyrinth) and the last scene frame (objects) received
+	var canvasOk = !!(canvas.getContext && window.requestAnimationFrame);
+	var sprites = new Image(), scene = null, background = null, sceneFrame = null, drawPending = false;
+	if (canvasOk) {
+		sprites.onload = buildBackground;
+		sprites.onerror = function() {
+			canvasOk = false; // Fall back to JPEG images
+			subscribe();
+		};
+		sprites.src = "/sprites";
+	}
+	canvasMode.disabled = !canvasOk;
+	canvasMode.onchange = function() { subscribe(); };
+	
+	showPaused({{.Paused}});
 	
-	pauseResume();
+	// Auto-pause when the page is hidden (e.g. switching to another browser tab):
+	document.addEventListener("visibilitychange", function() {
+		if (document.hidden && !paused)
+			pauseResume(true);
+	});
+	
+	// If a game is specified in the URL (shared link), start it:
+	var params = {};
+	location.search.substr(1).split("&").forEach(function(p) {
+		var kv = p.split("=");
+		params[kv[0]] = decodeURIComponent(kv[1]);
+	});
+	if (params.seed) {
+		if (params.algorithm)
+			algorithm.value = params.algorithm;
+		newGame(params.seed);
+		// Do not restart the game when the page is reloaded:
+		history.replaceState(null, "", "/");
+	}
+	
+	// Request the frames (or restart the stream) with the new parameters:
+	quality.onchange = fps.onchange = function() {
+		if (socket)
+			subscribe();
+		else if (streaming)
+			startStream();
+	}
 	
 	// Kick-off:
-	refresh();
-	setInterval(checkRunId, 10000);
+	if (window.WebSocket && window.URL && URL.createObjectURL)
+		connectSocket(true);
+	else
+		startRequests();
+	
+	// startRequests starts receiving the frames and the notifications of the game with separate requests
+	// (used if WebSocket is not available).
+	function startRequests() {
+		startStream();
+		if (window.EventSource)
+			listenEvents();
+		else {
+			// No Server-Sent Events, poll the running app id and the replay status
+			setInterval(checkRunId, 10000);
+			setInterval(checkReplay, 500);
+		}
+	}
+	
+	// connectSocket connects the WebSocket which carries the inputs, the frames and the notifications of the game.
+	// If the first connection fails, the page falls back to separate requests.
+	// A broken connection is reconnected, resuming the session.
+	function connectSocket(first) {
+		var ws = new WebSocket((location.protocol == "https:" ? "wss://" : "ws://") + location.host + "/api/v1/socket");
+		var opened = false;
+		ws.onopen = function() {
+			opened = true;
+			socket = ws;
+			errMsg.style.visibility = "hidden";
+			subscribe();
+		};
+		ws.onmessage = function(e) {
+			if (typeof e.data != "string") {
+				showFrame(e.data);
+				return;
+			}
+			var m = JSON.parse(e.data);
+			switch (m.type) {
+			case "hello":
+				if (m.runId != runId)
+					window.location.reload(); // App was restarted, reload page
+				break;
+			case "event":
+				var ev = m.event;
+				ev.you = m.you;
+				ev.name = m.name;
+				eventHandlers[ev.type](ev);
+				break;
+			case "scene":
+				scene = m.scene;
+				buildBackground();
+				break;
+			case "sceneFrame":
+				sceneFrame = m.sceneFrame;
+				if (!drawPending) {
+					drawPending = true;
+					requestAnimationFrame(drawScene);
+				}
+				break;
+			case "replay":
+				showReplay(m.replay);
+				break;
+			case "newGame":
+				// New game was started
+				showGame(m.game);
+				showPaused(false); // New game is never paused
+				break;
+			case "error":
+				showStatus(m.error);
+				break;
+			}
+		};
+		ws.onclose = function() {
+			socket = null;
+			if (first && !opened) {
+				startRequests();
+				return;
+			}
+			errMsg.style.visibility = "visible";
+			setTimeout(function() { connectSocket(false); }, 2000);
+		};
+	}
+	
+	// send sends the specified request on the WebSocket. Returns false if it is not connected.
+	function send(req) {
+		if (!socket)
+			return false;
+		socket.send(JSON.stringify(req));
+		return true;
+	}
+	
+	// subscribe requests the frames of the view (or the scene if the view is drawn on the canvas)
+	// on the WebSocket with the selected parameters.
+	function subscribe() {
+		var useCanvas = canvasOk && canvasMode.checked;
+		if (!useCanvas)
+			showCanvas(false);
+		send({type: "subscribe", frames: !useCanvas, scene: useCanvas, fps: Math.round(1000 / fps.value), quality: Number(quality.value)});
+	}
+	
+	// showCanvas shows the canvas (if c is true) or the image as the view.
+	function showCanvas(c) {
+		canvas.style.display = c ? "inline" : "none";
+		img.style.display = c ? "none" : "inline";
+	}
+	
+	// buildBackground draws the static part of the scene (walls and exit) onto the background canvas.
+	function buildBackground() {
+		background = null;
+		if (!scene || !sprites.complete || !sprites.naturalWidth)
+			return;
+		var sp = scene.sprites, bs = sp.blockSize;
+		background = document.createElement("canvas");
+		background.width = scene.width;
+		background.height = scene.height;
+		var ctx = background.getContext("2d");
+		ctx.fillStyle = "#000";
+		ctx.fillRect(0, 0, scene.width, scene.height);
+		for (var r = 0; r < scene.lab.length; r++)
+			for (var c = 0; c < scene.lab[r].length; c++)
+				if (scene.lab[r].charAt(c) == "#")
+					ctx.drawImage(sprites, sp.wall.X, sp.wall.Y, bs, bs, c * bs, r * bs, bs, bs);
+		ctx.drawImage(sprites, sp.exit.X, sp.exit.Y, bs, bs, scene.exit.X - bs / 2, scene.exit.Y - bs / 2, bs, bs);
+	}
+	
+	// drawScene draws the last scene frame onto the canvas.
+	function drawScene() {
+		drawPending = false;
+		if (!background || !sceneFrame)
+			return;
+		var f = sceneFrame, sp = scene.sprites, bs = sp.blockSize, v = f.view;
+		var w = v.Max.X - v.Min.X, h = v.Max.Y - v.Min.Y;
+		var ctx = canvas.getContext("2d");
+		
+		ctx.fillStyle = "#000";
+		ctx.fillRect(0, 0, canvas.width, canvas.height);
+		ctx.drawImage(background, v.Min.X, v.Min.Y, w, h, 0, 0, w, h);
+		
+		ctx.save();
+		ctx.beginPath();
+		ctx.rect(0, 0, w, h);
+		ctx.clip();
+		ctx.translate(-v.Min.X, -v.Min.Y);
+		var t = sp.target, tw = t.Max.X - t.Min.X, th = t.Max.Y - t.Min.Y;
+		(f.targets || []).forEach(function(p) {
+			ctx.drawImage(sprites, t.Min.X, t.Min.Y, tw, th, p.X - Math.floor(tw / 2), p.Y - Math.floor(th / 2), tw, th);
+		});
+		var drawObj = function(cell, o) {
+			ctx.drawImage(sprites, cell.X, cell.Y, bs, bs, o.x - bs / 2, o.y - bs / 2, bs, bs);
+		};
+		f.gophers.forEach(function(o, i) { drawObj(o.dead ? sp.dead[i] : sp.gophers[i][o.dir], o); });
+		f.bulldogs.forEach(function(o) { drawObj(sp.bulldogs[o.dir], o); });
+		ctx.restore();
+		
+		// Overlays, the same as drawn onto the view images:
+		if (f.hud) {
+			ctx.font = "bold 16px Arial";
+			ctx.textAlign = "left";
+			ctx.textBaseline = "top";
+			ctx.fillStyle = "rgba(0, 0, 0, 0.5)";
+			ctx.fillRect(2, 2, ctx.measureText(f.hud).width + 8, 24);
+			ctx.fillStyle = "#fff";
+			ctx.fillText(f.hud, 6, 6);
+		}
+		if (f.wonImg) {
+			var won = sp.won, ww = won.Max.X - won.Min.X, wh = won.Max.Y - won.Min.Y;
+			ctx.drawImage(sprites, won.Min.X, won.Min.Y, ww, wh, Math.floor((w - ww) / 2), Math.floor((h - wh) / 2), ww, wh);
+		} else if (f.lines)
+			drawLines(ctx, f.lines, 30, w, h);
+		if (f.paused)
+			drawLines(ctx, ["Paused"], 60, w, h);
+		
+		showCanvas(true);
+	}
+	
+	// drawLines draws the specified lines of text centered on a semi-transparent panel,
+	// with the biggest font size (not bigger than max) at which they fit into the view.
+	function drawLines(ctx, lines, max, w, h) {
+		var width = function() {
+			var mw = 0;
+			lines.forEach(function(l) { mw = Math.max(mw, ctx.measureText(l).width); });
+			return mw;
+		};
+		ctx.font = "bold " + max + "px Arial";
+		var size = Math.max(10, Math.min(max, Math.floor(max * w * 0.8 / (width() || 1))));
+		ctx.font = "bold " + size + "px Arial";
+		ctx.textAlign = "center";
+		ctx.textBaseline = "middle";
+		var lh = size * 1.5, pad = size, tw = width(), th = (lines.length - 1) * lh + size;
+		ctx.fillStyle = "rgba(0, 0, 0, 0.75)";
+		ctx.fillRect((w - tw) / 2 - pad, (h - th) / 2 - pad, tw + 2 * pad, th + 2 * pad);
+		ctx.fillStyle = "#fff";
+		lines.forEach(function(l, i) { ctx.fillText(l, w / 2, (h - th) / 2 + size / 2 + i * lh); });
+	}
+	
+	// showFrame displays a frame received on the WebSocket.
+	function showFrame(blob) {
+		if (frameUrl)
+			URL.revokeObjectURL(frameUrl);
+		frameUrl = URL.createObjectURL(blob);
+		img.src = frameUrl;
+	}
 	
-	function pauseResume() {
-		playing = !playing;
+	// pauseResume pauses or resumes the game.
+	function pauseResume(p) {
+		showPaused(p);
+		if (send({type: "pause", paused: p}))
+			return;
+		var r = new XMLHttpRequest();
+		r.open("GET", "/pause?p=" + (p ? 1 : 0) + "&t=" + new Date().getTime(), true);
+		r.send(null);
+	}
+	
+	function showPaused(p) {
+		paused = p;
+		pauseResumeBtn.innerText = paused ? "Resume" : "Pause";
+	}
+	
+	// startStream starts (or restarts) the MJPEG stream of the view.
+	// Falls back to polling the images if the stream fails or does not deliver an image in 5 seconds.
+	function startStream() {
+		streaming = true;
+		imgLoaded = false;
+		var id = ++streamId;
+		img.src = "/stream?quality=" + quality.value + "&fps=" + Math.round(1000 / fps.value) + "&t=" + new Date().getTime();
+		setTimeout(function() {
+			if (streaming && id == streamId && !imgLoaded)
+				stopStream();
+		}, 5000);
+	}
+	
+	// stopStream stops the MJPEG stream, and switches to polling the images.
+	function stopStream() {
+		streaming = false;
 		imgLoaded = true;
-		pauseResumeBtn.innerText = playing ? "Freeze" : "Resume";
+		refresh();
+	}
+	
+	function imgError() {
+		errMsg.style.visibility = "visible";
+		if (streaming)
+			stopStream();
+		else
+			setTimeout("imgLoaded = true;", 1000);
 	}
 	
 	function refresh() {
-		if (playing && imgLoaded) {
+		if (imgLoaded) {
 			imgLoaded = false;
 			img.src = "/img?quality=" + quality.value + "&t=" + new Date().getTime();
 			setTimeout(refresh, fps.value);
@@ -116,7 +451,7 @@ const play_html = `<html>
 	}
 	
 	function imgClicked(e) {
-		if (!playing)
+		if (paused)
 			return;
 		// Relative mouse coordinates inside image:
 		var x, y;
@@ -126,20 +461,75 @@ const play_html = `<html>
 		} else {            // For other browsers:
 	    	x = e.clientX;
 	    	y = e.clientY;
-	    	for (var el = img; el; el = el.offsetParent) {
+	    	for (var el = e.currentTarget; el; el = el.offsetParent) {
 	    		x -= el.offsetLeft - el.scrollLeft + el.clientLeft;
         		y -= el.offsetTop - el.scrollTop + el.clientTop;
 	    	}
     	}
     	
+		if (send({type: "click", x: x, y: y, btn: e.button, pathFinding: pathFinding.checked}))
+			return;
 		var r = new XMLHttpRequest();
-		r.open("GET", "/clicked?x=" + x + "&y=" + y + "&b=" + e.button + "&t=" + new Date().getTime(), true);
+		r.open("GET", "/clicked?x=" + x + "&y=" + y + "&b=" + e.button + "&p=" + (pathFinding.checked ? 1 : 0) + "&t=" + new Date().getTime(), true);
 		r.send(null);
 	}
 	
+	// Keyboard control: arrow keys and WASD
+	var keyDirs = {37: "left", 38: "up", 39: "right", 40: "down", 65: "left", 87: "up", 68: "right", 83: "down"};
+	document.onkeydown = function(e) { return keyEvent(e, 1); };
+	document.onkeyup = function(e) { return keyEvent(e, 0); };
+	
+	function keyEvent(e, pressed) {
+		var dir = keyDirs[e.keyCode];
+		if (!dir || paused || e.ctrlKey || e.altKey || e.metaKey || e.target.tagName == "SELECT")
+			return true;
+		if (!e.repeat && !send({type: "command", command: {dir: dir, pressed: pressed == 1}})) {
+			var r = new XMLHttpRequest();
+			r.open("GET", "/key?d=" + dir + "&s=" + pressed + "&t=" + new Date().getTime(), true);
+			r.send(null);
+		}
+		return false; // Prevent scrolling the page with the arrow keys
+	}
+	
+	// Handlers of the notifications of the game, by event type:
+	var eventHandlers = {
+		gameStarted: function(ev) {
+			showGame(ev.game);
+			showPaused(false); // New game is never paused
+			showStatus(ev.replay ? "Playing back a replay." : "");
+		},
+		gopherDied: function(ev) {
+			showStatus(ev.you ? "You were caught by a Bulldog! Start a New Game to try again." : ev.name + " was caught by a Bulldog.");
+		},
+		gopherWon: function(ev) {
+			showStatus(ev.you ? "You reached the Exit!" : ev.name + " reached the Exit.");
+		},
+		waypointRejected: function(ev) {
+			showStatus("That block cannot be reached" + (pathFinding.checked ? "." : " in a straight line."));
+		}
+	};
+	
+	// listenEvents listens to the notifications of the game sent as Server-Sent Events.
+	function listenEvents() {
+		var events = new EventSource("/events");
+		var on = function(type, handler) {
+			events.addEventListener(type, function(e) { handler(JSON.parse(e.data)); });
+		};
+		on("run", function(id) {
+			if (id != runId)
+				window.location.reload(); // App was restarted, reload page
+		});
+		for (var type in eventHandlers)
+			on(type, eventHandlers[type]);
+		on("replay", showReplay);
+	}
+	
+	// showStatus displays the specified status text.
+	function showStatus(text) {
+		statusDiv.innerText = text;
+	}
+	
 	function checkRunId() {
-		if (!playing)
-			return;
 		var r = new XMLHttpRequest();
 		r.open("GET", "/runid?t=" + new Date().getTime(), true);
 		r.onreadystatechange = function() {
@@ -149,17 +539,84 @@ const play_html = `<html>
 		r.send(null);
 	}
 	
-	function newGame() {
+	function newGame(seed) {
+		if (send({type: "new", game: {algorithm: algorithm.value, seed: seed || "0"}}))
+			return;
 		var r = new XMLHttpRequest();
-		r.open("GET", "/new?t=" + new Date().getTime(), true);
+		r.open("GET", "/new?algorithm=" + algorithm.value + (seed ? "&seed=" + seed : "") + "&t=" + new Date().getTime(), true);
 		r.onreadystatechange = function() {
-			if (r.readyState == 4 && r.status == 200 && runId != r.responseText)
-				// New game was started, reload page
-				if (!playing)
-					pauseResume();
+			if (r.readyState == 4 && r.status == 200) {
+				// New game was started
+				showGame(JSON.parse(r.responseText));
+				showPaused(false); // New game is never paused
+			}
 		};
 		r.send(null);
 	}
+	
+	// Replay mode:
+	var seeking = false; // Tells if the user is dragging the seek bar
+	
+	replayFile.onchange = function() {
+		if (!replayFile.files.length)
+			return;
+		var r = new XMLHttpRequest();
+		r.open("POST", "/replay", true);
+		r.onreadystatechange = function() {
+			if (r.readyState == 4) {
+				if (r.status == 200)
+					showPaused(false); // Replays start playing
+				else
+					alert(r.responseText);
+			}
+		};
+		r.send(replayFile.files[0]);
+		replayFile.value = "";
+	};
+	
+	seekBar.oninput = function() { seeking = true; };
+	seekBar.onchange = function() {
+		seeking = false;
+		var r = new XMLHttpRequest();
+		r.open("GET", "/replay?seek=" + seekBar.value + "&t=" + new Date().getTime(), true);
+		r.send(null);
+	};
+	
+	function checkReplay() {
+		var r = new XMLHttpRequest();
+		r.open("GET", "/replay?t=" + new Date().getTime(), true);
+		r.onreadystatechange = function() {
+			if (r.readyState == 4 && r.status == 200)
+				showReplay(JSON.parse(r.responseText));
+		};
+		r.send(null);
+	}
+	
+	// showReplay displays the replay status.
+	function showReplay(s) {
+		replayBar.style.display = s.replaying ? "block" : "none";
+		if (!s.replaying)
+			return;
+		if (s.paused != paused)
+			showPaused(s.paused);
+		seekBar.max = s.ticks;
+		if (!seeking)
+			seekBar.value = s.tick;
+		replayTime.innerText = formatTicks(s.tick, s.tickRate) + " / " + formatTicks(s.ticks, s.tickRate);
+	}
+	
+	// formatTicks formats a number of ticks as time in the form of "M:SS.T".
+	function formatTicks(ticks, tickRate) {
+		var t = Math.floor(ticks * 10 / tickRate);
+		return Math.floor(t / 600) + ":" + ("0" + Math.floor(t / 10) % 60).slice(-2) + "." + t % 10;
+	}
+	
+	// showGame displays the parameters of the current game.
+	function showGame(g) {
+		algorithm.value = g.algorithm;
+		seedLink.innerText = "Seed: " + g.seed;
+		seedLink.href = "/?seed=" + g.seed + "&algorithm=" + g.algorithm;
+	}
 </script>
 
 </body>
@@ -0,0 +1,65 @@
+package view
+
+import (
+	"bytes"
+	"github.com/gophergala/golab/model"
+	"image/png"
+	"net/http"
+	"sync"
+)
+
+var (
+	// spritesPNG is the sprite sheet for client-side rendering encoded in PNG format, spriteLayout is its layout
+	spritesPNG   []byte
+	spriteLayout *model.SpriteLayout
+
+	// spritesOnce is used to create the sprite sheet on first use
+	spritesOnce sync.Once
+)
+
+// sprites returns the sprite sheet for client-side rendering in PNG format, and its layout.
+func sprites() ([]byte, *model.SpriteLayout) {
+	spritesOnce.Do(func() {
+		sheet, layout := model.SpriteSheet()
+		var buf bytes.Buffer
+		png.Encode(&buf, sheet)
+		spritesPNG, spriteLayout = buf.Bytes(), layout
+	})
+	return spritesPNG, spriteLayout
+}
+
+// spritesHandle serves the sprite sheet for client-side rendering (see model.SpriteSheet).
+func spritesHandle(w http.ResponseWriter, r *http.Request) {
+	data, _ := sprites()
+	w.Header().Set("Content-Type", "image/png")
+	// Sprites only change if the app is restarted, which reloads the page
+	w.Header().Set("Cache-Control", "max-age=86400")
+	w.Write(data)
+}
+
+// scene returns the static part of the view of the session in the specified snapshot of its game
+// for client-side rendering.
+func (s *session) scene(snap *model.Snapshot) *model.Scene {
+	sc := snap.Scene()
+	_, sc.Sprites = sprites()
+	return sc
+}
+
+// sceneFrame returns the moving part of the view of the session in the specified snapshot of its game
+// for client-side rendering, with the same overlays as drawn onto the view images.
+// The position of the view is stored in the session.
+func (s *session) sceneFrame(snap *model.Snapshot) *model.SceneFrame {
+	f := snap.SceneFrame()
+	f.View = s.viewRect(snap)
+	if len(snap.Campaign) > 0 || snap.Hunting {
+		f.HUD = hudText(snap)
+	}
+	if snap.Won {
+		if f.Lines = snap.WonLines; len(f.Lines) == 0 {
+			f.WonImg = true
+		}
+	} else if p := s.playerOf(snap); !s.hunter && p.Finished {
+		f.Lines = finishedLines(p)
+	}
+	return f
+}
@@ -0,0 +1,287 @@
+package view
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/gophergala/golab/ctrl"
+	"github.com/gophergala/golab/model"
+	"image"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionTimeout is the time after which idle sessions are discarded along with their games.
+var SessionTimeout = 10 * time.Minute
+
+// MaxGames is the maximum number of running games of the sessions, no new sessions are created above it.
+var MaxGames = 100
+
+// InitialReplay is the replay to play back in the games of new sessions, nil means new sessions start a new game.
+var InitialReplay *model.Replay
+
+// Name of the cookie holding the session id.
+const sessionCookie = "golab-session"
+
+// session is a browser session: a player playing its own game, or racing in the game of another session.
+// The game and the player of a session never change, joining a game replaces the session.
+type session struct {
+	// game of the session
+	game *model.Game
+
+	// gameId is the id of the game, other sessions can join the game by it
+	gameId string
+
+	// player is the index of the player of the session in the game
+	player int
+
+	// hunter tells if the player of the session is the hunter (controlling a Bulldog), player is not used then
+	hunter bool
+
+	// mutex protects pos
+	mutex sync.Mutex
+
+	// The client's (browser's) view position inside the Labyrinth image. This is the top-left point of the view.
+	pos image.Point
+
+	// lastAccess is the time of the last request of the session, protected by sessionsMutex
+	lastAccess time.Time
+}
+
+var (
+	// sessions maps from session id to session
+	sessions = map[string]*session{}
+
+	// Mutex to be used to synchronize access to sessions
+	sessionsMutex sync.Mutex
+
+	// games is the number of running games of the sessions, protected by sessionsMutex
+	games int
+)
+
+var (
+	// errNoSession is the error of requests of clients having no session (or whose session has expired)
+	errNoSession = errors.New("No session, open the play page or start a new game")
+
+	// errTooManyGames is the error of requests which would start a new game when MaxGames games are running
+	errTooManyGames = errors.New("Too many games, retry later")
+)
+
+// init starts the garbage collector of idle sessions.
+func init() {
+	go gcSessions()
+}
+
+// getSession returns the session of the client.
+// If the client has no session (or it has expired), errNoSession is replied and nil is returned:
+// sessions are only created by the play page, by starting a new game and by joining a game (see newSession).
+func getSession(w http.ResponseWriter, r *http.Request) *session {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	_, s := lookupSession(r)
+	if s == nil {
+		http.Error(w, errNoSession.Error(), http.StatusUnauthorized)
+		return nil
+	}
+	s.lastAccess = time.Now()
+	return s
+}
+
+// newSession returns the session of the client.
+// If the client has no session (or it has expired), a new session is created with a new game,
+// unless MaxGames games are running in which case errTooManyGames is returned.
+func newSession(w http.ResponseWriter, r *http.Request) (*session, error) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	if _, s := lookupSession(r); s != nil {
+		s.lastAccess = time.Now()
+		return s, nil
+	}
+	if games >= MaxGames {
+		return nil, errTooManyGames
+	}
+
+	id := newSessionId()
+	s := &session{game: ctrl.StartEngine(), gameId: newSessionId(), lastAccess: time.Now()}
+	games++
+	watchGame(s.gameId, s.game)
+	// Channels cannot block, engine was just started
+	s.game.NewGameCh <- model.NewGame{}
+	if InitialReplay != nil {
+		s.game.ReplayCh <- model.ReplayCmd{Replay: InitialReplay}
+	}
+	sessions[id] = s
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/", HttpOnly: true})
+	return s, nil
+}
+
+// lookupSession returns the id and the session of the client, the session is nil if the client has none.
+// sessionsMutex must be locked when called.
+func lookupSession(r *http.Request) (string, *session) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", nil
+	}
+	return c.Value, sessions[c.Value]
+}
+
+// touch updates the last access time of the session, so long-lived connections keep it alive.
+func (s *session) touch() {
+	sessionsMutex.Lock()
+	s.lastAccess = time.Now()
+	sessionsMutex.Unlock()
+}
+
+// joinGame makes the session of the client join the game having the specified id as a new player,
+// or as the hunter if hunter is true.
+// The game is restarted so the race (or the hunt) starts over with all the players.
+// The Mutex of the game is locked without holding sessionsMutex, so a busy engine does not block other sessions.
+func joinGame(w http.ResponseWriter, r *http.Request, gameId string, hunter bool) error {
+	sessionsMutex.Lock()
+	if _, s := lookupSession(r); s != nil && s.gameId == gameId {
+		s.lastAccess = time.Now()
+		sessionsMutex.Unlock()
+		return nil // Already in the game
+	}
+	var g *model.Game
+	for _, s2 := range sessions {
+		if s2.gameId == gameId {
+			g = s2.game
+			break
+		}
+	}
+	sessionsMutex.Unlock()
+	if g == nil {
+		return errors.New("the game does not exist (anymore)")
+	}
+
+	g.Mutex.Lock()
+	player := 0
+	if hunter {
+		if !g.JoinHunter() {
+			player = -1
+		}
+	} else {
+		player = g.Join()
+	}
+	ng := g.CurGame
+	g.Mutex.Unlock()
+	if player < 0 {
+		return errors.New("the game is full")
+	}
+
+	sessionsMutex.Lock()
+	select {
+	case <-g.Quit:
+		// The last session of the game left it meanwhile
+		sessionsMutex.Unlock()
+		return errors.New("the game does not exist (anymore)")
+	default:
+	}
+	id, s := lookupSession(r)
+	if s == nil {
+		// Joining starts a new session without a game of its own
+		id = newSessionId()
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/", HttpOnly: true})
+	}
+	sessions[id] = &session{game: g, gameId: gameId, player: player, hunter: hunter, lastAccess: time.Now()}
+	sessionsMutex.Unlock()
+
+	// Restart the game (if the new game is dropped, the one sent concurrently restarts it)
+	sendNewGame(g, ng)
+
+	if s != nil {
+		leaveGame(s)
+	}
+	return nil
+}
+
+// leaveGame makes the player of the specified session (which has been discarded or replaced) leave its game:
+// the game is stopped if no other session plays it, else the slot of the player is released.
+// sessionsMutex must not be locked when called (the Mutex of the game is locked after releasing it).
+func leaveGame(s *session) {
+	g := s.game
+	sessionsMutex.Lock()
+	released := releaseGame(g)
+	sessionsMutex.Unlock()
+	if released {
+		return
+	}
+	g.Mutex.Lock()
+	if s.hunter {
+		g.LeaveHunter()
+	} else {
+		g.Leave(s.player)
+	}
+	g.Mutex.Unlock()
+}
+
+// releaseGame stops the specified game if no session plays it.
+// Returns true if the game was stopped (also if it had been stopped already).
+// sessionsMutex must be locked when called.
+func releaseGame(g *model.Game) bool {
+	select {
+	case <-g.Quit:
+		return true // Sessions leaving the game concurrently
+	default:
+	}
+	for _, s := range sessions {
+		if s.game == g {
+			return false
+		}
+	}
+	close(g.Quit)
+	games--
+	return true
+}
+
+// center returns the center of the view of the session in the specified snapshot of its game:
+// the position of the Bulldog of the hunter if the session is the hunter, else the position of the Gopher of the player.
+func (s *session) center(snap *model.Snapshot) image.Point {
+	if bd := snap.HunterBulldog(); s.hunter && bd != nil {
+		return image.Pt(int(bd.Pos.X), int(bd.Pos.Y))
+	}
+	p := s.playerOf(snap)
+	return image.Pt(int(p.Pos.X), int(p.Pos.Y))
+}
+
+// playerOf returns the player of the session in the specified snapshot of its game.
+// If the game has no such player (e.g. a replay with fewer players is played back), the first player is returned.
+func (s *session) playerOf(snap *model.Snapshot) *model.Player {
+	if s.player < len(snap.Players) {
+		return snap.Players[s.player]
+	}
+	return snap.Players[0]
+}
+
+// newSessionId returns a new, random session id.
+func newSessionId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // Should never happen
+	}
+	return hex.EncodeToString(b)
+}
+
+// gcSessions periodically discards the idle sessions: their players leave their games,
+// and their games which are not played by other sessions are stopped.
+func gcSessions() {
+	for range time.Tick(time.Minute) {
+		var idle []*session
+		sessionsMutex.Lock()
+		for id, s := range sessions {
+			if time.Since(s.lastAccess) > SessionTimeout {
+				delete(sessions, id)
+				idle = append(idle, s)
+			}
+		}
+		sessionsMutex.Unlock()
+
+		for _, s := range idle {
+			leaveGame(s)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gophergala/golab/model"
+	"github.com/gophergala/golab/websocket"
+	"net/http"
+	"time"
+)
+
+// socketKeepAlive is the interval of the pings sent on WebSockets (which also keep the session alive).
+const socketKeepAlive = 30 * time.Second
+
+// socketWriteTimeout is the time limit of writing a message to a WebSocket, slower clients are disconnected.
+const socketWriteTimeout = 10 * time.Second
+
+// socketHandle serves the WebSocket of the API which carries both the inputs of the client (model.SocketRequest)
+// and the updates of the server (model.SocketMessage and frames), replacing the separate requests
+// of the UI web page (and the polling of the bot API).
+//
+// The session of the client is identified by its cookie just like with other requests, so a client reconnecting
+// resumes its session (and its game); clients having no session are rejected (see getSession).
+// The first message is a model.SocketHello telling the running app id. Game events and the changes of the replay status
+// are always sent, frames and states only after a model.SocketSubscribe request, at most FPS times per second and only if the engine rendered a new frame.
+// Frames (JPEG images or scene frames for client-side rendering) and states are created from the latest snapshot
+// of the game when they are sent, so a slow client skips frames.
+func socketHandle(w http.ResponseWriter, r *http.Request) {
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return // Upgrade replied the error
+	}
+	defer conn.Close()
+
+	g := s.game
+	sub := g.Events.Subscribe(20)
+	defer sub.Close()
+
+	// Requests are read by a separate goroutine, all messages are written by this one
+	reqs := make(chan model.SocketRequest, 10)
+	readDone, stop := make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(readDone)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return // Client went away
+			}
+			var req model.SocketRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				req = model.SocketRequest{} // Replied as an invalid request
+			}
+			select {
+			case reqs <- req:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	send := func(msg *model.SocketMessage) bool {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return true
+		}
+		conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+		return conn.WriteMessage(websocket.TextMessage, data) == nil
+	}
+
+	if !send(&model.SocketMessage{Type: model.SocketHello, RunId: runId}) {
+		return
+	}
+
+	var subscr model.SocketRequest // The current subscription
+	quality := 70
+	// The ticker of the frames and states, it is stopped while nothing is subscribed
+	ticker := time.NewTicker(time.Hour)
+	ticker.Stop()
+	defer func() { ticker.Stop() }() // ticker is replaced on subscription
+	keepAlive := time.NewTicker(socketKeepAlive)
+	defer keepAlive.Stop()
+	replayTicker := time.NewTicker(replayCheck)
+	defer replayTicker.Stop()
+
+	lastFrame := int64(-1)
+	var sceneLevel *model.Level // The level of the last scene sent
+	var lastReplay *model.ReplayStatus
+	for {
+		select {
+		case <-readDone:
+			return // Client went away
+		case <-g.Quit:
+			return // Engine of the game stopped
+		case <-keepAlive.C:
+			s.touch()
+			conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			if conn.Ping() != nil {
+				return
+			}
+		case <-replayTicker.C:
+			st := g.Snapshot().ReplayStatus()
+			if !replayChanged(st, lastReplay) {
+				continue
+			}
+			lastReplay = &st
+			if !send(&model.SocketMessage{Type: model.SocketReplay, Replay: &st}) {
+				return
+			}
+		case ev, ok := <-sub.C:
+			if !ok {
+				return // Engine of the game stopped, the client will reconnect
+			}
+			you, ok := s.clientEvent(ev)
+			if !ok {
+				continue
+			}
+			if !send(&model.SocketMessage{Type: model.SocketEvent, Event: &ev, You: you, Name: model.PlayerNames[ev.Player]}) {
+				return
+			}
+		case req := <-reqs:
+			s.touch()
+			if req.Type == model.SocketSubscribe {
+				subscr = req
+				if subscr.FPS < 1 || subscr.FPS > 60 {
+					subscr.FPS = 20
+				}
+				quality = 70
+				if q := subscr.Quality; q != nil && *q >= 0 && *q <= 100 {
+					quality = *q
+				}
+				ticker.Stop()
+				if subscr.Frames || subscr.States || subscr.Scene {
+					ticker = time.NewTicker(time.Second / time.Duration(subscr.FPS))
+					lastFrame, sceneLevel = -1, nil // Send the current frame (and scene) right away
+				}
+				continue
+			}
+			if reply := s.handleSocketRequest(req); reply != nil && !send(reply) {
+				return
+			}
+		case <-ticker.C:
+			// Ticks are dropped while a slow client is being written to
+			snap := g.Snapshot()
+			if snap.Frame == lastFrame {
+				continue // No new frame
+			}
+			lastFrame = snap.Frame
+			if subscr.States && !send(&model.SocketMessage{Type: model.SocketState, State: s.state(snap)}) {
+				return
+			}
+			if subscr.Scene {
+				if snap.CurLevel != sceneLevel {
+					sceneLevel = snap.CurLevel
+					if !send(&model.SocketMessage{Type: model.SocketScene, Scene: s.scene(snap)}) {
+						return
+					}
+				}
+				if !send(&model.SocketMessage{Type: model.SocketSceneFrame, SceneFrame: s.sceneFrame(snap)}) {
+					return
+				}
+			}
+			if subscr.Frames {
+				conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+				if conn.WriteMessage(websocket.BinaryMessage, s.viewJPEG(snap, quality)) != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// handleSocketRequest handles a request (other than model.SocketSubscribe) received on the WebSocket of the session.
+// Returns the reply to be sent, nil if there is none.
+func (s *session) handleSocketRequest(req model.SocketRequest) *model.SocketMessage {
+	var err error
+	switch req.Type {
+	case model.SocketClick:
+		s.click(req.X, req.Y, req.Btn, req.PathFinding)
+	case model.SocketCommand:
+		if req.Command == nil {
+			return &model.SocketMessage{Type: model.SocketError, Id: req.Id, Error: "Command is missing"}
+		}
+		err = s.command(*req.Command)
+	case model.SocketPause:
+		pauseGame(s.game, req.Paused)
+	case model.SocketNew:
+		var ng model.NewGame
+		if req.Game != nil {
+			ng = *req.Game
+		}
+		if ng, err = startNewGame(s.game, ng); err == nil {
+			return &model.SocketMessage{Type: model.SocketNewGame, Id: req.Id, Game: &ng}
+		}
+	default:
+		return &model.SocketMessage{Type: model.SocketError, Id: req.Id, Error: fmt.Sprintf("Invalid request type: %q", req.Type)}
+	}
+	if err != nil {
+		return &model.SocketMessage{Type: model.SocketError, Id: req.Id, Error: err.Error()}
+	}
+	return nil
+}
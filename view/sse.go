@@ -0,0 +1,126 @@
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gophergala/golab/model"
+	"net/http"
+	"time"
+)
+
+// sseKeepAlive is the interval of the keep-alive comments sent on idle Server-Sent Events streams,
+// so proxies do not close them. The session of the client is also touched at this interval.
+const sseKeepAlive = 30 * time.Second
+
+// replayCheck is the interval of checking the replay status of the game, changes are pushed to the clients
+// on the WebSocket and the Server-Sent Events stream.
+const replayCheck = 500 * time.Millisecond
+
+// replayChanged tells if the replay status st is to be pushed to a client which was sent the status last
+// (nil if none was sent yet). Outside of replay mode, only entering replay mode is a change.
+func replayChanged(st model.ReplayStatus, last *model.ReplayStatus) bool {
+	return last == nil || (st.Replaying || last.Replaying) && st != *last
+}
+
+// sseEvent is a game event as sent to the clients on the Server-Sent Events stream.
+type sseEvent struct {
+	model.Event
+
+	// You tells if the event concerns the player of the client
+	You bool `json:"you"`
+
+	// Name is the name of the player concerned
+	Name string `json:"name"`
+}
+
+// sseEventTypes are the types of game events sent to the clients.
+var sseEventTypes = map[model.EventType]bool{
+	model.EventGameStarted:      true,
+	model.EventGopherDied:       true,
+	model.EventGopherWon:        true,
+	model.EventWaypointRejected: true,
+}
+
+// clientEvent tells if the specified event is to be sent to the client of the session,
+// and if so, whether it concerns the player of the client.
+func (s *session) clientEvent(ev model.Event) (you, ok bool) {
+	if !sseEventTypes[ev.Type] {
+		return false, false
+	}
+	switch ev.Type {
+	case model.EventGameStarted:
+		return true, true // Concerns everyone
+	case model.EventWaypointRejected:
+		// Only the own waypoints are of interest
+		you = ev.Hunter == s.hunter && (s.hunter || ev.Player == s.player)
+		return you, you
+	}
+	return !s.hunter && ev.Player == s.player, true
+}
+
+// eventsHandle serves the notifications of the game of the client as a Server-Sent Events stream:
+// the game started, a Gopher died or reached the exit, a waypoint of the client was rejected.
+// The first event of the stream is the running app id (a "run" event), so clients reconnecting
+// after the app was restarted detect the restart right away.
+// Changes of the replay status are sent as "replay" events (model.ReplayStatus).
+func eventsHandle(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	sub := s.game.Events.Subscribe(20)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "retry: 2000\nevent: run\ndata: %d\n\n", runId)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	replayTicker := time.NewTicker(replayCheck)
+	defer replayTicker.Stop()
+
+	var lastReplay *model.ReplayStatus
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return // Client went away
+		case <-keepAlive.C:
+			s.touch() // The session is alive while the stream is open
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-replayTicker.C:
+			st := s.game.Snapshot().ReplayStatus()
+			if !replayChanged(st, lastReplay) {
+				continue
+			}
+			lastReplay = &st
+			data, err := json.Marshal(st)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: replay\ndata: %s\n\n", data)
+		case ev, ok := <-sub.C:
+			if !ok {
+				return // Engine of the game stopped, the client will reconnect
+			}
+			you, ok := s.clientEvent(ev)
+			if !ok {
+				continue
+			}
+			data, err := json.Marshal(sseEvent{Event: ev, You: you, Name: model.PlayerNames[ev.Player]})
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+		}
+		flusher.Flush()
+	}
+}
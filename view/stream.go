@@ -0,0 +1,76 @@
+package view
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// streamBoundary is the boundary of the parts (images) of the MJPEG stream.
+const streamBoundary = "golabframe"
+
+// streamTouch is the interval of touching the session of the clients receiving the MJPEG stream,
+// so the session is not discarded while the view is streamed.
+const streamTouch = 30 * time.Second
+
+// streamHandle serves the player's view as an MJPEG stream (multipart/x-mixed-replace of JPEG images).
+//
+// The frame rate is specified by the "fps" parameter (1..60, 20 by default), the JPEG quality by the "quality" parameter.
+// A new image is only sent if the engine rendered a new frame since the last one.
+// Images are encoded from the latest snapshot of the game when they are sent,
+// so a slow client skips frames instead of queuing them.
+func streamHandle(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	fps, err := strconv.Atoi(r.FormValue("fps"))
+	if err != nil || fps < 1 || fps > 60 {
+		fps = 20
+	}
+	quality := parseQuality(r)
+
+	s := getSession(w, r)
+	if s == nil {
+		return
+	}
+	g := s.game
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+streamBoundary)
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "--%s\r\n", streamBoundary)
+
+	// Ticks are dropped while a slow client is being written to
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	defer ticker.Stop()
+	touch := time.NewTicker(streamTouch)
+	defer touch.Stop()
+
+	lastFrame := int64(-1)
+	for {
+		if snap := g.Snapshot(); snap.Frame != lastFrame {
+			lastFrame = snap.Frame
+			img := s.viewJPEG(snap, quality)
+			// The boundary is written right after the image, so browsers display it without waiting for the next one
+			fmt.Fprintf(w, "Content-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", len(img))
+			w.Write(img)
+			if _, err := fmt.Fprintf(w, "\r\n--%s\r\n", streamBoundary); err != nil {
+				return // Client went away
+			}
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return // Client went away
+		case <-g.Quit:
+			return // Engine of the game stopped
+		case <-touch.C:
+			s.touch()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,423 @@
+/*
+Package websocket is a minimal implementation of the WebSocket protocol (RFC 6455), both the server side
+(Upgrade) and the client side (Dial), sufficient for the needs of GoLab without external dependencies.
+
+Messages are read and written as a whole. Fragmented messages are reassembled, ping frames are answered
+automatically, and closing handshakes are replied. Extensions and subprotocols are not supported.
+*/
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of messages
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Opcodes of the frames (besides the message types)
+const (
+	continuationFrame = 0
+	closeFrame        = 8
+	pingFrame         = 9
+	pongFrame         = 10
+)
+
+// Status codes of close frames
+const (
+	closeNormal       = 1000
+	closeProtocolErr  = 1002
+	closeTooBig       = 1009
+	closeNoStatusRcvd = 1005
+)
+
+// MaxMessageSize is the default maximum size of a received message in bytes (see Conn.SetReadLimit),
+// larger messages close the connection.
+const MaxMessageSize = 64 << 10
+
+// acceptGUID is the GUID appended to the key of the handshake to compute the accept value.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrProtocol is returned when the peer violates the WebSocket protocol.
+var ErrProtocol = errors.New("websocket: protocol error")
+
+// Conn is a WebSocket connection.
+//
+// Messages are to be read by one goroutine at a time. WriteMessage is safe for concurrent use.
+type Conn struct {
+	// conn is the underlying network connection
+	conn net.Conn
+
+	// br is the buffered reader of conn
+	br *bufio.Reader
+
+	// client tells if this is the client side of the connection (client frames are masked)
+	client bool
+
+	// readLimit is the maximum size of a received message in bytes
+	readLimit int
+
+	// writeMutex serializes the frames written to conn, and protects closeSent
+	writeMutex sync.Mutex
+
+	// closeSent tells if a close frame has been sent
+	closeSent bool
+}
+
+// Upgrade upgrades the HTTP server connection of the request to the WebSocket protocol.
+// The headers already set in w (e.g. cookies) are sent in the handshake response.
+// If the request is not a valid WebSocket handshake, an HTTP error is replied and an error is returned.
+//
+// Browsers send the Origin header: handshakes from pages of other hosts are rejected, so third-party pages
+// cannot open connections with the cookies of the user. Requests without Origin (non-browser clients) are accepted.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != "GET" || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "WebSocket handshake expected", http.StatusBadRequest)
+		return nil, errors.New("websocket: not a handshake request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return nil, errors.New("websocket: origin not allowed")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket is not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: connection cannot be hijacked")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	h := w.Header()
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Accept", acceptKey(key))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	h.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: conn, br: brw.Reader, readLimit: MaxMessageSize}, nil
+}
+
+// sameOrigin tells if the Origin header of the request is missing or its host matches the host of the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Dial opens a WebSocket connection to the specified URL (ws:// or wss://, http:// and https:// are also accepted).
+// header holds additional headers of the handshake request (e.g. cookies), it may be nil.
+// The handshake response is also returned (e.g. to process its cookies).
+func Dial(rawurl string, header http.Header) (*Conn, *http.Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws", "http":
+		if u.Port() == "" {
+			host += ":80"
+		}
+		conn, err = net.DialTimeout("tcp", host, 10*time.Second)
+	case "wss", "https":
+		if u.Port() == "" {
+			host += ":443"
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme: %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(b)
+
+	req := &http.Request{Method: "GET", URL: u, Host: u.Host, Header: http.Header{}}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	bw := bufio.NewWriter(conn)
+	fmt.Fprintf(bw, "GET %s HTTP/1.1\r\nHost: %s\r\n", u.RequestURI(), u.Host)
+	req.Header.Write(bw)
+	bw.WriteString("\r\n")
+	if err := bw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, resp, fmt.Errorf("websocket: handshake failed: %s", resp.Status)
+	}
+
+	return &Conn{conn: conn, br: br, client: true, readLimit: MaxMessageSize}, resp, nil
+}
+
+// acceptKey returns the accept value of the handshake for the specified key.
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains tells if the specified header contains the specified token (case-insensitive).
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage reads the next data message (TextMessage or BinaryMessage).
+// Control frames are handled while waiting for it. If the peer closed the connection, io.EOF is returned.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		fin, op, payload, err := c.readFrame(c.readLimit - len(data))
+		if err != nil {
+			if err == ErrProtocol {
+				c.writeClose(closeProtocolErr)
+			}
+			return 0, nil, err
+		}
+
+		switch op {
+		case pingFrame:
+			if err := c.writeFrame(pongFrame, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case pongFrame:
+			continue
+		case closeFrame:
+			code := closeNoStatusRcvd
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			if code == closeNoStatusRcvd {
+				code = closeNormal
+			}
+			c.writeClose(code)
+			return 0, nil, io.EOF
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				c.writeClose(closeProtocolErr)
+				return 0, nil, ErrProtocol // Data frame inside of a fragmented message
+			}
+			messageType = op
+		case continuationFrame:
+			if messageType == 0 {
+				c.writeClose(closeProtocolErr)
+				return 0, nil, ErrProtocol // Continuation without a message
+			}
+		default:
+			c.writeClose(closeProtocolErr)
+			return 0, nil, ErrProtocol
+		}
+
+		data = append(data, payload...)
+		if fin {
+			return messageType, data, nil
+		}
+	}
+}
+
+// readFrame reads a frame whose payload must not be larger than max bytes (control frames are always allowed).
+func (c *Conn) readFrame(max int) (fin bool, op int, payload []byte, err error) {
+	var h [8]byte
+	if _, err = io.ReadFull(c.br, h[:2]); err != nil {
+		return
+	}
+	fin, op = h[0]&0x80 != 0, int(h[0]&0x0f)
+	masked, n := h[1]&0x80 != 0, uint64(h[1]&0x7f)
+	if h[0]&0x70 != 0 || masked == c.client {
+		// Reserved bits are not used, frames of clients must be masked, frames of servers must not
+		return false, 0, nil, ErrProtocol
+	}
+
+	switch n {
+	case 126:
+		if _, err = io.ReadFull(c.br, h[:2]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(h[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, h[:8]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(h[:8])
+	}
+
+	if op >= closeFrame {
+		if !fin || n > 125 {
+			return false, 0, nil, ErrProtocol
+		}
+	} else if n > uint64(max) {
+		c.writeClose(closeTooBig)
+		return false, 0, nil, fmt.Errorf("websocket: message exceeds %d bytes", c.readLimit)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// SetReadLimit sets the maximum size of a received message in bytes (MaxMessageSize by default).
+// Must be called before reading messages.
+func (c *Conn) SetReadLimit(limit int) {
+	c.readLimit = limit
+}
+
+// WriteMessage writes a message of the specified type (TextMessage or BinaryMessage) as a single frame.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type: %d", messageType)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// Ping sends a ping frame, the peer answers it with a pong frame (which is discarded by ReadMessage).
+// Can be used to keep idle connections alive.
+func (c *Conn) Ping() error {
+	return c.writeFrame(pingFrame, nil)
+}
+
+// writeFrame writes a final frame with the specified opcode and payload.
+func (c *Conn) writeFrame(op int, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if c.closeSent {
+		return errors.New("websocket: connection is closed")
+	}
+	if op == closeFrame {
+		c.closeSent = true
+	}
+
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|byte(op))
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, maskBit|126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = append(buf, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(n))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		buf = append(buf, mask[:]...)
+		for i, b := range payload {
+			buf = append(buf, b^mask[i%4])
+		}
+	} else {
+		buf = append(buf, payload...)
+	}
+
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// writeClose sends a close frame with the specified status code (if no close frame has been sent yet).
+func (c *Conn) writeClose(code int) {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], uint16(code))
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writeFrame(closeFrame, payload[:])
+}
+
+// SetReadDeadline sets the deadline of reading messages, see net.Conn.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of writing messages, see net.Conn.
+// A write that timed out leaves the connection in an undefined state, it is to be closed.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// Close sends a close frame (if it has not been sent yet), and closes the underlying connection.
+func (c *Conn) Close() error {
+	c.writeClose(closeNormal)
+	return c.conn.Close()
+}
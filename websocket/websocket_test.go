@@ -0,0 +1,224 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startEchoServer starts a test server which echoes the messages received on its WebSocket.
+// The error ending the connection on the server side is sent on the returned channel.
+func startEchoServer() (*httptest.Server, chan error) {
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := conn.WriteMessage(typ, data); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}))
+	return srv, errs
+}
+
+// dial connects to the WebSocket of the test server.
+func dial(t *testing.T, srv *httptest.Server) *Conn {
+	conn, _, err := Dial(srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// writeRawFrame writes a masked client frame with the specified fin bit, opcode and payload.
+func writeRawFrame(c *Conn, fin bool, op int, payload []byte) error {
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	buf := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, 0x80|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 0x80|126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 0x80|127)
+		buf = append(buf, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(n))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	buf = append(buf, mask[:]...)
+	for i, b := range payload {
+		buf = append(buf, b^mask[i%4])
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func TestRoundTrip(t *testing.T) {
+	srv, _ := startEchoServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+	defer conn.Close()
+
+	msgs := []struct {
+		typ  int
+		data []byte
+	}{
+		{TextMessage, []byte(`{"type":"subscribe"}`)},
+		{BinaryMessage, bytes.Repeat([]byte{0xff, 0}, 1000)}, // 16 bit length
+		{TextMessage, []byte{}},
+	}
+	for _, m := range msgs {
+		if err := conn.WriteMessage(m.typ, m.data); err != nil {
+			t.Fatal(err)
+		}
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != m.typ || !bytes.Equal(data, m.data) {
+			t.Errorf("got message %d of %d bytes, want %d of %d bytes", typ, len(data), m.typ, len(m.data))
+		}
+	}
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	srv, _ := startEchoServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+	defer conn.Close()
+
+	// A ping between the fragments is answered, the fragments are reassembled
+	frames := []struct {
+		fin     bool
+		op      int
+		payload string
+	}{
+		{false, TextMessage, "Hello, "},
+		{true, pingFrame, "ping"},
+		{false, continuationFrame, "Go"},
+		{true, continuationFrame, "Lab!"},
+	}
+	for _, f := range frames {
+		if err := writeRawFrame(conn, f.fin, f.op, []byte(f.payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The pong is discarded by ReadMessage
+	typ, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != TextMessage || string(data) != "Hello, GoLab!" {
+		t.Errorf("got message %d %q, want %d %q", typ, data, TextMessage, "Hello, GoLab!")
+	}
+}
+
+func TestOversizedMessage(t *testing.T) {
+	cases := []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"single frame", [][]byte{make([]byte, MaxMessageSize+1)}},
+		{"fragments", [][]byte{make([]byte, MaxMessageSize/2), make([]byte, MaxMessageSize/2+1)}},
+	}
+	for _, c := range cases {
+		srv, errs := startEchoServer()
+		conn := dial(t, srv)
+
+		for i, f := range c.frames {
+			op := TextMessage
+			if i > 0 {
+				op = continuationFrame
+			}
+			if err := writeRawFrame(conn, i == len(c.frames)-1, op, f); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The server closes the connection
+		if _, _, err := conn.ReadMessage(); err != io.EOF {
+			t.Errorf("%s: got %v, want io.EOF", c.name, err)
+		}
+		if err := <-errs; err == nil || !strings.Contains(err.Error(), "exceeds") {
+			t.Errorf("%s: got server error %v", c.name, err)
+		}
+
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestSetReadLimit(t *testing.T) {
+	srv, _ := startEchoServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+	defer conn.Close()
+
+	// The echo of a message is refused by the client having a lower limit
+	conn.SetReadLimit(10)
+	if err := conn.WriteMessage(BinaryMessage, make([]byte, 11)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("got %v, want message size error", err)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	srv, errs := startEchoServer()
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	cases := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://" + host, true},
+		{"http://evil.example.com", false},
+		{"http://" + host + ".evil.example.com", false},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		if c.origin != "" {
+			header.Set("Origin", c.origin)
+		}
+		conn, resp, err := Dial(srv.URL, header)
+		if (err == nil) != c.ok {
+			t.Errorf("origin %q: got error %v, want ok %v", c.origin, err, c.ok)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+		// The server ends the connection (or rejects the handshake)
+		serr := <-errs
+		if c.ok {
+			continue
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("origin %q: got response %v, want status %d", c.origin, resp, http.StatusForbidden)
+		}
+		if serr == nil || !strings.Contains(serr.Error(), "origin") {
+			t.Errorf("origin %q: got server error %v", c.origin, serr)
+		}
+	}
+}